@@ -0,0 +1,312 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Ext types of arbitrary-precision numbers, values of these types decode into *big.Int and *big.Float.
+//
+// The data of a BigIntExtType value is a sign byte, 0 for positive numbers and zero and 1 for negative numbers,
+// followed by the absolute value as big-endian bytes without leading zeros.
+//
+// The data of a BigFloatExtType value is the precision in bits as a big-endian uint32,
+// followed by the number in decimal text, such as "-1.5e+400", "+Inf" or "-Inf".
+// The precision is at most 4 bits per byte of text plus 64 and at most MaxBigFloatPrec,
+// parsing the text at a higher precision is slow and adds nothing the text holds.
+const (
+	BigIntExtType   int8 = 98  // 'b'
+	BigFloatExtType int8 = 102 // 'f'
+)
+
+// MaxBigFloatPrec is the highest precision in bits of a BigFloatExtType value.
+const MaxBigFloatPrec = 1 << 16
+
+// NumberOverflow selects what happens to a json.Number that does not fit in the 64-bit MessagePack numbers.
+type NumberOverflow int
+
+const (
+	// NumberOverflowFloat writes integers outside the 64-bit range as a float64, losing precision,
+	// and reports floats outside the float64 range as an error wrapping ErrOverflow.
+	NumberOverflowFloat NumberOverflow = iota
+	// NumberOverflowError reports the number as an error wrapping ErrOverflow.
+	NumberOverflowError
+	// NumberOverflowString writes the number as a str value holding its text.
+	NumberOverflowString
+	// NumberOverflowBigExt writes the number as a BigIntExtType or BigFloatExtType ext value.
+	NumberOverflowBigExt
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// appendBigInt appends x to dst as a BigIntExtType ext value.
+func appendBigInt(dst []byte, x *big.Int) []byte {
+	data := make([]byte, 1+(x.BitLen()+7)/8)
+	if x.Sign() < 0 {
+		data[0] = 1
+	}
+	x.FillBytes(data[1:])
+	return AppendExt(dst, BigIntExtType, data)
+}
+
+// appendBigFloat appends x to dst as a BigFloatExtType ext value.
+func appendBigFloat(dst []byte, x *big.Float) []byte {
+	return appendBigFloatText(dst, x.Prec(), x.Append(nil, 'g', -1))
+}
+
+// appendBigFloatText appends the number text of precision prec to dst as a BigFloatExtType ext value,
+// a precision above the limit of the text is written as that limit.
+func appendBigFloatText(dst []byte, prec uint, text []byte) []byte {
+	if limit := bigFloatPrecLimit(len(text)); prec > limit {
+		prec = limit
+	}
+	data := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(text)), uint32(prec))
+	data = append(data, text...)
+	return AppendExt(dst, BigFloatExtType, data)
+}
+
+// bigFloatPrecLimit returns the highest precision of a BigFloatExtType value with textLen bytes of text.
+func bigFloatPrecLimit(textLen int) uint {
+	if textLen >= (MaxBigFloatPrec-64)/4 {
+		return MaxBigFloatPrec
+	}
+	return uint(4*textLen + 64)
+}
+
+// decodeBigInt decodes the data of a BigIntExtType ext value.
+func decodeBigInt(data []byte) (*big.Int, error) {
+	if len(data) == 0 || data[0] > 1 {
+		return nil, fmt.Errorf("invalid big.Int ext data")
+	}
+
+	x := new(big.Int).SetBytes(data[1:])
+	if data[0] == 1 {
+		x.Neg(x)
+	}
+	return x, nil
+}
+
+// decodeBigFloat decodes the data of a BigFloatExtType ext value.
+func decodeBigFloat(data []byte) (*big.Float, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("invalid big.Float ext data")
+	}
+
+	prec := binary.BigEndian.Uint32(data)
+	if uint(prec) > bigFloatPrecLimit(len(data)-4) {
+		return nil, fmt.Errorf("invalid big.Float precision %d for %d bytes of text", prec, len(data)-4)
+	}
+
+	x, _, err := big.ParseFloat(string(data[4:]), 10, uint(prec), big.ToNearestEven)
+	if err != nil {
+		return nil, fmt.Errorf("invalid big.Float ext data: %w", err)
+	}
+	return x, nil
+}
+
+// decodeBigExt decodes an ext token of one of the big number types into the Go type selected by mode,
+// ok is false for other ext types.
+func decodeBigExt(tok Token, mode NumberMode) (value interface{}, ok bool, err error) {
+	switch tok.ExtType {
+	case BigIntExtType:
+		x, err := decodeBigInt(tok.Bytes)
+		if err != nil {
+			return nil, true, err
+		}
+		switch mode {
+		case NumberJSON:
+			return json.Number(x.String()), true, nil
+		case NumberFloat64:
+			f, _ := new(big.Float).SetInt(x).Float64()
+			return f, true, nil
+		}
+		return x, true, nil
+
+	case BigFloatExtType:
+		x, err := decodeBigFloat(tok.Bytes)
+		if err != nil {
+			return nil, true, err
+		}
+		switch mode {
+		case NumberJSON:
+			// formatting a large exponent is slow, numbers in JSON syntax are kept as they are
+			if text := tok.Bytes[4:]; json.Valid(text) {
+				return json.Number(text), true, nil
+			}
+			return json.Number(x.Text('g', -1)), true, nil
+		case NumberFloat64:
+			f, _ := x.Float64()
+			return f, true, nil
+		}
+		return x, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// encodeMsgPackTypeNumberFamily writes num as the smallest integer encoding that holds it, or as a float64.
+// Numbers outside of those ranges are handled according to opts.NumberOverflow.
+func (m *Msgpack) encodeMsgPackTypeNumberFamily(result *[]byte, num json.Number, opts *encodeState) (*[]byte, error) {
+	str := num.String()
+
+	if ui, ok := parseUint(str); ok {
+		*result = AppendUint(*result, ui)
+		return result, nil
+	}
+	if i, ok := parseInt(str); ok {
+		*result = AppendInt(*result, i)
+		return result, nil
+	}
+
+	// an integer outside of the 64-bit range
+	if !strings.ContainsAny(str, ".eE") {
+		x, ok := new(big.Int).SetString(str, 10)
+		if !ok {
+			return result, fmt.Errorf("invalid number %q", str)
+		}
+
+		switch opts.NumberOverflow {
+		case NumberOverflowFloat:
+			if f, err := strconv.ParseFloat(str, 64); err == nil {
+				encodeFloat64(result, f, opts)
+				return result, nil
+			}
+		case NumberOverflowBigExt:
+			*result = appendBigInt(*result, x)
+			return result, nil
+		}
+		return result, m.encodeNumberOverflow(result, str, opts)
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err == nil {
+		encodeFloat64(result, f, opts)
+		return result, nil
+	}
+	if !errors.Is(err, strconv.ErrRange) {
+		return result, fmt.Errorf("invalid number %q", str)
+	}
+
+	// a float outside of the float64 range, with at least the precision of its digits
+	if opts.NumberOverflow == NumberOverflowBigExt {
+		prec := uint(len(str)) * 4
+		if prec < 64 {
+			prec = 64
+		} else if prec > MaxBigFloatPrec {
+			prec = MaxBigFloatPrec
+		}
+		if _, _, err := big.ParseFloat(str, 10, prec, big.ToNearestEven); err != nil {
+			return result, fmt.Errorf("invalid number %q", str)
+		}
+		// the text is written as it is, formatting a large exponent is slow
+		*result = appendBigFloatText(*result, prec, []byte(str))
+		return result, nil
+	}
+	return result, m.encodeNumberOverflow(result, str, opts)
+}
+
+// encodeNumberOverflow writes str as a string under NumberOverflowString and reports an error otherwise.
+func (m *Msgpack) encodeNumberOverflow(result *[]byte, str string, opts *encodeState) error {
+	if opts.NumberOverflow == NumberOverflowString {
+		return m.encodeString(result, str, opts)
+	}
+	return fmt.Errorf("%w: json.Number %s does not fit in 64 bits", ErrOverflow, str)
+}
+
+// assignBigValue stores the big numbers value into rv, and numbers into big number destinations.
+// It reports false when neither applies.
+func assignBigValue(rv reflect.Value, value interface{}) (bool, error) {
+	switch rv.Type() {
+	case bigIntType:
+		x := rv.Addr().Interface().(*big.Int)
+		switch v := value.(type) {
+		case *big.Int:
+			x.Set(v)
+			return true, nil
+		case json.Number:
+			if _, ok := x.SetString(string(v), 10); ok {
+				return true, nil
+			}
+		}
+		switch val := reflect.ValueOf(value); val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x.SetInt64(val.Int())
+			return true, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			x.SetUint64(val.Uint())
+			return true, nil
+		}
+		return true, fmt.Errorf("cannot decode %T into Go value of type %s", value, rv.Type())
+
+	case bigFloatType:
+		x := rv.Addr().Interface().(*big.Float)
+		switch v := value.(type) {
+		case *big.Float:
+			x.Set(v)
+			return true, nil
+		case *big.Int:
+			x.SetInt(v)
+			return true, nil
+		case json.Number:
+			if _, ok := x.SetString(string(v)); ok {
+				return true, nil
+			}
+		}
+		switch val := reflect.ValueOf(value); val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			x.SetInt64(val.Int())
+			return true, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			x.SetUint64(val.Uint())
+			return true, nil
+		case reflect.Float32, reflect.Float64:
+			if math.IsNaN(val.Float()) {
+				return true, fmt.Errorf("cannot decode NaN into Go value of type %s", rv.Type())
+			}
+			x.SetFloat64(val.Float())
+			return true, nil
+		}
+		return true, fmt.Errorf("cannot decode %T into Go value of type %s", value, rv.Type())
+
+	case jsonNumberType:
+		switch v := value.(type) {
+		case *big.Int:
+			rv.SetString(v.String())
+			return true, nil
+		case *big.Float:
+			rv.SetString(v.Text('g', -1))
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// bigToBasic returns the int64, uint64 or float64 a big number converts to without overflowing,
+// or the number itself when it does not fit.
+func bigToBasic(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		if v.IsInt64() {
+			return v.Int64()
+		}
+		if v.IsUint64() {
+			return v.Uint64()
+		}
+	case *big.Float:
+		if f, _ := v.Float64(); v.IsInf() || !math.IsInf(f, 0) {
+			return f
+		}
+	}
+	return value
+}
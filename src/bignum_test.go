@@ -0,0 +1,276 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalBigNumbers(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - positive big.Int",
+			input:    big.NewInt(0x0102),
+			expected: []byte{0xc7, 0x03, 0x62, 0x00, 0x01, 0x02},
+		},
+		{
+			desc:     "Test Case - negative big.Int",
+			input:    big.NewInt(-1),
+			expected: []byte{0xd5, 0x62, 0x01, 0x01},
+		},
+		{
+			desc:     "Test Case - zero big.Int",
+			input:    new(big.Int),
+			expected: []byte{0xd4, 0x62, 0x00},
+		},
+		{
+			desc:     "Test Case - nil big.Int",
+			input:    (*big.Int)(nil),
+			expected: []byte{0xc0},
+		},
+		{
+			desc:  "Test Case - big.Float",
+			input: big.NewFloat(1.5),
+			expected: []byte{
+				0xc7, 0x07, 0x66,
+				0x00, 0x00, 0x00, 0x35, 0x31, 0x2e, 0x35,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestNumberOverflow(t *testing.T) {
+	const bigInt = "123456789012345678901234567890"
+	const bigFloat = "1.5e400"
+
+	testCases := []struct {
+		desc     string
+		overflow NumberOverflow
+		input    json.Number
+		expected interface{}
+		err      error
+	}{
+		{
+			desc:     "Test Case - integer as float",
+			overflow: NumberOverflowFloat,
+			input:    bigInt,
+			expected: 1.2345678901234568e+29,
+		},
+		{
+			desc:     "Test Case - float out of range as float",
+			overflow: NumberOverflowFloat,
+			input:    bigFloat,
+			err:      ErrOverflow,
+		},
+		{
+			desc:     "Test Case - integer as error",
+			overflow: NumberOverflowError,
+			input:    bigInt,
+			err:      ErrOverflow,
+		},
+		{
+			desc:     "Test Case - integer as string",
+			overflow: NumberOverflowString,
+			input:    bigInt,
+			expected: bigInt,
+		},
+		{
+			desc:     "Test Case - float as string",
+			overflow: NumberOverflowString,
+			input:    bigFloat,
+			expected: bigFloat,
+		},
+		{
+			desc:     "Test Case - integer as big ext",
+			overflow: NumberOverflowBigExt,
+			input:    bigInt,
+			expected: bigIntValue(bigInt),
+		},
+		{
+			desc:     "Test Case - negative integer as big ext",
+			overflow: NumberOverflowBigExt,
+			input:    "-" + bigInt,
+			expected: bigIntValue("-" + bigInt),
+		},
+		{
+			desc:     "Test Case - invalid number",
+			overflow: NumberOverflowBigExt,
+			input:    "12abc",
+		},
+		{
+			desc:     "Test Case - invalid float",
+			overflow: NumberOverflowString,
+			input:    "1.2.3",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := NewMsgpack(WithNumberOverflow(tc.overflow))
+
+			encoded, err := mp.Marshal(map[string]interface{}{"n": tc.input})
+			if tc.expected == nil {
+				require.Error(t, err)
+				if tc.err != nil {
+					require.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := mp.Unmarshal(encoded)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result["n"])
+		})
+	}
+}
+
+func TestBigFloatOverflowRoundTrip(t *testing.T) {
+	mp := NewMsgpack(WithNumberOverflow(NumberOverflowBigExt), WithNumberMode(NumberJSON))
+
+	encoded, err := mp.Marshal(map[string]interface{}{"n": json.Number("-1.5e400")})
+	require.NoError(t, err)
+
+	result, err := mp.Unmarshal(encoded)
+	require.NoError(t, err)
+	require.Equal(t, json.Number("-1.5e400"), result["n"])
+}
+
+func TestBigFloatPrecisionLimit(t *testing.T) {
+	mp := NewMsgpack(WithNumberOverflow(NumberOverflowBigExt))
+
+	// a precision above the limit of the text is written as that limit
+	encoded, err := mp.AppendMarshal(nil, new(big.Float).SetPrec(1000).SetInt64(1))
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xc7, 0x05, 0x66, 0x00, 0x00, 0x00, 0x44, 0x31}, encoded)
+
+	var result *big.Float
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, "1", result.Text('g', -1))
+
+	// a long json.Number is parsed at no more than MaxBigFloatPrec
+	num := json.Number(strings.Repeat("7", 20000) + "e999999")
+	encoded, err = mp.AppendMarshal(nil, num)
+	require.NoError(t, err)
+
+	var long *big.Float
+	require.NoError(t, mp.UnmarshalTo(encoded, &long))
+	require.Equal(t, uint(MaxBigFloatPrec), long.Prec())
+
+	// and kept as it is as a json.Number
+	var text json.Number
+	require.NoError(t, NewMsgpack(WithNumberMode(NumberJSON)).UnmarshalTo(encoded, &text))
+	require.Equal(t, num, text)
+}
+
+func TestUnmarshalBigNumbers(t *testing.T) {
+	mp := NewMsgpack(WithNumberOverflow(NumberOverflowBigExt))
+
+	type ledger struct {
+		ID      *big.Int    `msgpack:"id"`
+		Total   big.Int     `msgpack:"total"`
+		Rate    *big.Float  `msgpack:"rate"`
+		Small   int64       `msgpack:"small"`
+		Text    json.Number `msgpack:"text"`
+		FromInt *big.Int    `msgpack:"from_int"`
+	}
+
+	input := map[string]interface{}{
+		"id":       json.Number("123456789012345678901234567890"),
+		"total":    json.Number("-98765432109876543210"),
+		"rate":     big.NewFloat(0.25),
+		"small":    big.NewInt(42),
+		"text":     json.Number("123456789012345678901234567890"),
+		"from_int": 7,
+	}
+	encoded, err := mp.Marshal(input)
+	require.NoError(t, err)
+
+	var result ledger
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, "123456789012345678901234567890", result.ID.String())
+	require.Equal(t, "-98765432109876543210", result.Total.String())
+	require.Equal(t, "0.25", result.Rate.Text('g', -1))
+	require.Equal(t, int64(42), result.Small)
+	require.Equal(t, json.Number("123456789012345678901234567890"), result.Text)
+	require.Equal(t, "7", result.FromInt.String())
+
+	// too big for an int64
+	var small struct {
+		ID int64 `msgpack:"id"`
+	}
+	require.Error(t, mp.UnmarshalTo(encoded, &small))
+
+	// round trip of the struct
+	reencoded, err := mp.AppendMarshal(nil, result)
+	require.NoError(t, err)
+
+	var again ledger
+	require.NoError(t, mp.UnmarshalTo(reencoded, &again))
+	require.Equal(t, result.Total.String(), again.Total.String())
+}
+
+func TestDecodeInvalidBigExt(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+	}{
+		{
+			desc:       "Test Case - empty big.Int",
+			inputBytes: []byte{0x81, 0xa1, 0x6e, 0xc7, 0x00, 0x62},
+		},
+		{
+			desc:       "Test Case - bad sign byte",
+			inputBytes: []byte{0x81, 0xa1, 0x6e, 0xd4, 0x62, 0x02},
+		},
+		{
+			desc:       "Test Case - short big.Float",
+			inputBytes: []byte{0x81, 0xa1, 0x6e, 0xd5, 0x66, 0x00, 0x00},
+		},
+		{
+			desc:       "Test Case - bad big.Float text",
+			inputBytes: []byte{0x81, 0xa1, 0x6e, 0xc7, 0x05, 0x66, 0x00, 0x00, 0x00, 0x40, 0x78},
+		},
+		// parsing a large exponent at a huge precision takes seconds
+		{
+			desc:       "Test Case - big.Float precision of 2^32-1",
+			inputBytes: append([]byte{0x81, 0xa1, 0x6e, 0xc7, 0x0f, 0x66, 0xff, 0xff, 0xff, 0xff}, "1e999999999"...),
+		},
+		{
+			desc:       "Test Case - big.Float precision above the limit of its text",
+			inputBytes: append([]byte{0x81, 0xa1, 0x6e, 0xc7, 0x0c, 0x66, 0x00, 0x00, 0x00, 0x61}, "1e999999"...),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := mp.Unmarshal(tc.inputBytes)
+			require.Error(t, err)
+		})
+	}
+}
+
+func bigIntValue(s string) *big.Int {
+	x, _ := new(big.Int).SetString(s, 10)
+	return x
+}
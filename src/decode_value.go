@@ -0,0 +1,325 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	MsgPackTypes "msgpack/src/types"
+)
+
+// decodeValue decodes the next value of r into the Go value rv.
+func (m *Msgpack) decodeValue(r *Reader, rv reflect.Value) error {
+	if r.offset >= len(r.data) {
+		return fmt.Errorf("data out of range")
+	}
+
+	// keep the encoded bytes of the value as they are
+	if rv.Type() == rawMessageType {
+		start := r.offset
+		if err := r.Skip(); err != nil {
+			return err
+		}
+		if err := r.allocate(r.offset - start); err != nil {
+			return err
+		}
+		rv.SetBytes(append(RawMessage(nil), r.data[start:r.offset]...))
+		return nil
+	}
+
+	// values of extension types, including pointers, are decoded whole
+	if m.extensionFor(rv.Type()) != nil {
+		value, err := m.decodeMsgpack(r)
+		if err != nil {
+			return err
+		}
+		return assignValue(rv, value)
+	}
+
+	currentByte := r.data[r.offset]
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if MsgPackTypes.IsMsgPackTypeNil(currentByte) {
+			r.offset++
+			rv.Set(reflect.Zero(rv.Type()))
+			return nil
+		}
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return m.decodeValue(r, rv.Elem())
+
+	case reflect.Interface:
+		// values of registered types are wrapped with their name
+		if m.registered.Load() {
+			if ok, err := m.decodeNamed(r, rv); ok || err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Struct:
+		start := r.offset
+		tok, err := r.Next()
+		if err != nil {
+			return err
+		}
+
+		// structs take maps and arrays
+		isMap := rv.Kind() == reflect.Map || rv.Kind() == reflect.Struct
+		isArray := rv.Kind() != reflect.Map
+		if tok.Kind == MapKind && isMap || tok.Kind == ArrayKind && isArray {
+			if err := r.enter(); err != nil {
+				return err
+			}
+			defer r.leave()
+
+			// struct fields and array elements are decoded in place
+			switch rv.Kind() {
+			case reflect.Struct:
+				if tok.Kind == ArrayKind {
+					return m.decodeStructArray(r, rv, tok.Length)
+				}
+				return m.decodeStructValue(r, rv, tok.Length)
+			case reflect.Array:
+				return m.decodeArrayValue(r, rv, tok.Length)
+			}
+
+			if err := r.allocate(tok.Length * int(rv.Type().Elem().Size())); err != nil {
+				return err
+			}
+
+			if tok.Kind == MapKind {
+				return m.decodeMapValue(r, rv, tok.Length)
+			}
+			return m.decodeSliceValue(r, rv, tok.Length)
+		}
+
+		// not a container, decode it as a plain value
+		r.offset = start
+	}
+
+	value, err := m.decodeMsgpack(r)
+	if err != nil {
+		return err
+	}
+	return assignValue(rv, value)
+}
+
+func (m *Msgpack) decodeMapValue(r *Reader, rv reflect.Value, mapLen int) error {
+	t := rv.Type()
+	if t.Key().Kind() != reflect.String {
+		return fmt.Errorf("cannot decode map into Go value of type %s", t)
+	}
+
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(t, mapLen))
+	}
+
+	for j := 0; j < mapLen; j++ {
+		// parse map key
+		key, err := m.handleMsgPackTypeMapKey(r)
+		if err != nil {
+			return err
+		}
+
+		// parse map value
+		elem := reflect.New(t.Elem()).Elem()
+		if err := m.decodeValue(r, elem); err != nil {
+			return err
+		}
+
+		rv.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+	}
+
+	return nil
+}
+
+func (m *Msgpack) decodeSliceValue(r *Reader, rv reflect.Value, arrLen int) error {
+	slice := reflect.MakeSlice(rv.Type(), arrLen, arrLen)
+	for j := 0; j < arrLen; j++ {
+		if err := m.decodeValue(r, slice.Index(j)); err != nil {
+			return err
+		}
+	}
+	rv.Set(slice)
+
+	return nil
+}
+
+// decodeArrayValue decodes the first elements of an array of arrLen elements into the Go array rv,
+// elements that do not fit are skipped and elements that are missing are set to zero.
+func (m *Msgpack) decodeArrayValue(r *Reader, rv reflect.Value, arrLen int) error {
+	for j := 0; j < arrLen; j++ {
+		if j >= rv.Len() {
+			if err := r.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.decodeValue(r, rv.Index(j)); err != nil {
+			return err
+		}
+	}
+	zeroArrayFrom(rv, arrLen)
+
+	return nil
+}
+
+// zeroArrayFrom sets the elements of the Go array rv from index i on to zero.
+func zeroArrayFrom(rv reflect.Value, i int) {
+	if i >= rv.Len() {
+		return
+	}
+	zero := reflect.Zero(rv.Type().Elem())
+	for ; i < rv.Len(); i++ {
+		rv.Index(i).Set(zero)
+	}
+}
+
+// setByteArray copies up to the length of the Go byte array rv from b and sets the rest to zero.
+func setByteArray(rv reflect.Value, b []byte) {
+	for i := 0; i < len(b) && i < rv.Len(); i++ {
+		rv.Index(i).SetUint(uint64(b[i]))
+	}
+	zeroArrayFrom(rv, len(b))
+}
+
+// assignValue stores a decoded value into rv, converting between numeric types when it fits.
+func assignValue(rv reflect.Value, value interface{}) error {
+	if value == nil {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	if ok, err := assignBigValue(rv, value); ok {
+		return err
+	}
+
+	// numbers decoded as json.Number or big numbers convert like the number they hold
+	if rv.Kind() != reflect.Interface && rv.Kind() != reflect.String {
+		if num, ok := value.(json.Number); ok {
+			value = numberValue(num)
+		}
+		value = bigToBasic(value)
+	}
+
+	val := reflect.ValueOf(value)
+
+	// values of extension types and other values of the destination type
+	if val.Type() == rv.Type() {
+		rv.Set(val)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if val.Type().Implements(rv.Type()) {
+			rv.Set(val)
+			return nil
+		}
+
+	case reflect.Bool:
+		if val.Kind() == reflect.Bool {
+			rv.SetBool(val.Bool())
+			return nil
+		}
+
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			rv.SetString(v)
+			return nil
+		case json.Number:
+			rv.SetString(string(v))
+			return nil
+		case []byte:
+			rv.SetString(string(v))
+			return nil
+		}
+
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := value.(type) {
+			case []byte:
+				rv.SetBytes(v)
+				return nil
+			case string:
+				rv.SetBytes([]byte(v))
+				return nil
+			}
+		}
+
+	case reflect.Array:
+		// bin and str values fill byte arrays like [16]byte IDs
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			switch v := value.(type) {
+			case []byte:
+				setByteArray(rv, v)
+				return nil
+			case string:
+				setByteArray(rv, []byte(v))
+				return nil
+			}
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := val.Int(); !rv.OverflowInt(n) {
+				rv.SetInt(n)
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if u := val.Uint(); u <= 1<<63-1 && !rv.OverflowInt(int64(u)) {
+				rv.SetInt(int64(u))
+				return nil
+			}
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n := val.Int(); n >= 0 && !rv.OverflowUint(uint64(n)) {
+				rv.SetUint(uint64(n))
+				return nil
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if u := val.Uint(); !rv.OverflowUint(u) {
+				rv.SetUint(u)
+				return nil
+			}
+		}
+
+	case reflect.Float32, reflect.Float64:
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			rv.SetFloat(float64(val.Int()))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			rv.SetFloat(float64(val.Uint()))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			rv.SetFloat(val.Float())
+			return nil
+		}
+	}
+
+	return fmt.Errorf("cannot decode %T into Go value of type %s", value, rv.Type())
+}
+
+// numberValue returns the uint64, int64 or float64 held by num, or num itself when it holds no number.
+func numberValue(num json.Number) interface{} {
+	str := num.String()
+
+	if ui, ok := parseUint(str); ok {
+		return ui
+	}
+	if i, ok := parseInt(str); ok {
+		return i
+	}
+	if f, err := num.Float64(); err == nil {
+		return f
+	}
+	return num
+}
@@ -0,0 +1,167 @@
+package msgpack
+
+import (
+	"fmt"
+)
+
+// entry describes where a map key or an array index sits in its enclosing container.
+type entry struct {
+	kind        Kind
+	count       int // number of elements or key-value pairs of the container
+	headerStart int
+	headerEnd   int
+	start       int // start of the entry, the key for maps
+	valueStart  int
+	end         int
+	found       bool
+}
+
+// Set returns a copy of data with the value at path replaced by newValue.
+// If the last path element is a map key that does not exist yet, the key is added to the map.
+// Only the replaced value and the header of its enclosing container are rewritten,
+// a fixmap that grows past 15 entries is upgraded to a map16.
+func (m *Msgpack) Set(data []byte, path []interface{}, newValue interface{}) ([]byte, error) {
+	opts := &encodeState{EncodeOptions: m.encodeOpts}
+
+	var encoded []byte
+	if err := m.handleValue(&encoded, newValue, opts); err != nil {
+		return nil, err
+	}
+
+	if len(path) == 0 {
+		return encoded, nil
+	}
+
+	e, err := locatePath(data, path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]byte, 0, len(data)+len(encoded))
+
+	if e.found {
+		result = append(result, data[:e.valueStart]...)
+		result = append(result, encoded...)
+		return append(result, data[e.end:]...), nil
+	}
+
+	key, ok := path[len(path)-1].(string)
+	if e.kind != MapKind || !ok {
+		return nil, fmt.Errorf("%w: index %v", ErrPathNotFound, path[len(path)-1])
+	}
+
+	// add the new key at the end of the map
+	result = append(result, data[:e.headerStart]...)
+	result = AppendMapHeader(result, e.count+1)
+	result = append(result, data[e.headerEnd:e.end]...)
+	if err := m.encodeMapKey(&result, key, opts); err != nil {
+		return nil, err
+	}
+	result = append(result, encoded...)
+	return append(result, data[e.end:]...), nil
+}
+
+// Delete returns a copy of data with the map key or array element at path removed.
+func (m *Msgpack) Delete(data []byte, path []interface{}) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot delete the root value")
+	}
+
+	e, err := locatePath(data, path)
+	if err != nil {
+		return nil, err
+	}
+	if !e.found {
+		return nil, fmt.Errorf("%w: %v", ErrPathNotFound, path[len(path)-1])
+	}
+
+	result := make([]byte, 0, len(data))
+	result = append(result, data[:e.headerStart]...)
+	if e.kind == MapKind {
+		result = AppendMapHeader(result, e.count-1)
+	} else {
+		result = AppendArrayHeader(result, e.count-1)
+	}
+	result = append(result, data[e.headerEnd:e.start]...)
+	return append(result, data[e.end:]...), nil
+}
+
+// locatePath finds the entry for the last element of path.
+func locatePath(data []byte, path []interface{}) (entry, error) {
+	var i int
+	if err := lookup(data, &i, path[:len(path)-1]); err != nil {
+		return entry{}, err
+	}
+	return locate(data, i, path[len(path)-1])
+}
+
+// locate finds key in the container that starts at data[offset].
+// When a map key is not found, start, valueStart and end point at the end of the map.
+func locate(data []byte, offset int, key interface{}) (entry, error) {
+	e := entry{headerStart: offset}
+	i := offset
+
+	switch key := key.(type) {
+	case string:
+		mapLen, err := readMapHeader(data, &i)
+		if err != nil {
+			return e, err
+		}
+		e.kind, e.count, e.headerEnd = MapKind, mapLen, i
+
+		for j := 0; j < mapLen; j++ {
+			e.start = i
+
+			strLen, err := readStringHeader(data, &i)
+			if err != nil {
+				return e, err
+			}
+			if i+strLen > len(data) {
+				return e, fmt.Errorf("insufficient data for string of length %d", strLen)
+			}
+
+			match := string(data[i:i+strLen]) == key
+			i += strLen
+
+			e.valueStart = i
+			if i, err = Skip(data, i); err != nil {
+				return e, err
+			}
+
+			if match {
+				e.end = i
+				e.found = true
+				return e, nil
+			}
+		}
+		e.start, e.valueStart, e.end = i, i, i
+
+	case int:
+		arrLen, err := readArrayHeader(data, &i)
+		if err != nil {
+			return e, err
+		}
+		e.kind, e.count, e.headerEnd = ArrayKind, arrLen, i
+
+		if key < 0 || key >= arrLen {
+			return e, nil
+		}
+
+		for j := 0; j < key; j++ {
+			if i, err = Skip(data, i); err != nil {
+				return e, err
+			}
+		}
+
+		e.start, e.valueStart = i, i
+		if e.end, err = Skip(data, i); err != nil {
+			return e, err
+		}
+		e.found = true
+
+	default:
+		return e, fmt.Errorf("unsupported path element of type %T", key)
+	}
+
+	return e, nil
+}
@@ -0,0 +1,164 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// {"v": 1, "user": {"email": "a@b", "tags": ["x", "y"]}}
+var editInputBytes = []byte{
+	0x82,
+	0xa1, 0x76, 0x01,
+	0xa4, 0x75, 0x73, 0x65, 0x72, 0x82,
+	0xa5, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0xa3, 0x61, 0x40, 0x62,
+	0xa4, 0x74, 0x61, 0x67, 0x73, 0x92, 0xa1, 0x78, 0xa1, 0x79,
+}
+
+func TestSet(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		path     []interface{}
+		newValue interface{}
+		expected map[string]interface{}
+	}{
+		{
+			desc:     "Test Case - replace number",
+			path:     []interface{}{"v"},
+			newValue: json.Number("300"),
+			expected: map[string]interface{}{
+				"v":    300,
+				"user": map[string]interface{}{"email": "a@b", "tags": []interface{}{"x", "y"}},
+			},
+		},
+		{
+			desc:     "Test Case - redact nested string",
+			path:     []interface{}{"user", "email"},
+			newValue: "***",
+			expected: map[string]interface{}{
+				"v":    1,
+				"user": map[string]interface{}{"email": "***", "tags": []interface{}{"x", "y"}},
+			},
+		},
+		{
+			desc:     "Test Case - replace array element",
+			path:     []interface{}{"user", "tags", 0},
+			newValue: []interface{}{true},
+			expected: map[string]interface{}{
+				"v":    1,
+				"user": map[string]interface{}{"email": "a@b", "tags": []interface{}{[]interface{}{true}, "y"}},
+			},
+		},
+		{
+			desc:     "Test Case - add key",
+			path:     []interface{}{"user", "name"},
+			newValue: "Ann",
+			expected: map[string]interface{}{
+				"v":    1,
+				"user": map[string]interface{}{"email": "a@b", "name": "Ann", "tags": []interface{}{"x", "y"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output, err := mp.Set(editInputBytes, tc.path, tc.newValue)
+			require.NoError(t, err)
+
+			jsonObj, err := mp.Unmarshal(output)
+			require.NoError(t, err)
+
+			jsonExpected, err := json.Marshal(tc.expected)
+			require.NoError(t, err)
+
+			jsonOutput, err := json.Marshal(jsonObj)
+			require.NoError(t, err)
+
+			require.Equal(t, string(jsonExpected), string(jsonOutput), "The two JSON string should be equal")
+		})
+	}
+}
+
+func TestSetReplaceKeepsRestInPlace(t *testing.T) {
+	mp := NewMsgpack()
+
+	output, err := mp.Set(editInputBytes, []interface{}{"v"}, json.Number("2"))
+	require.NoError(t, err)
+
+	expected := append([]byte{}, editInputBytes...)
+	expected[3] = 0x02
+	require.Equal(t, expected, output)
+}
+
+func TestSetUpgradesFixMap(t *testing.T) {
+	mp := NewMsgpack()
+
+	// fixmap with 15 keys "a".."o"
+	input := []byte{0x8f}
+	for c := byte('a'); c <= 'o'; c++ {
+		input = append(input, 0xa1, c, 0xc0)
+	}
+
+	output, err := mp.Set(input, []interface{}{"p"}, true)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xde, 0x00, 0x10}, output[:3])
+	require.Equal(t, input[1:], output[3:len(output)-3])
+	require.Equal(t, []byte{0xa1, 0x70, 0xc3}, output[len(output)-3:])
+
+	jsonObj, err := mp.Unmarshal(output)
+	require.NoError(t, err)
+	require.Len(t, jsonObj, 16)
+}
+
+func TestSetNewKeyEncodeOptions(t *testing.T) {
+	long := string(bytes.Repeat([]byte{0x61}, 40))
+
+	// new keys are written as raw16 instead of str8
+	mp := NewMsgpack(WithEncodeOptions(EncodeOptions{LegacyRaw: true}))
+	output, err := mp.Set([]byte{0x80}, []interface{}{long}, 1)
+	require.NoError(t, err)
+	require.Equal(t, append(append([]byte{0x81, 0xda, 0x00, 0x28}, long...), 0x01), output)
+
+	// invalid new keys are reported
+	mp = NewMsgpack(WithEncodeOptions(EncodeOptions{InvalidUTF8: UTF8AsBytes}))
+	_, err = mp.Set([]byte{0x80}, []interface{}{"a\xffb"}, 1)
+	require.ErrorIs(t, err, ErrInvalidUTF8)
+}
+
+func TestSetNotFound(t *testing.T) {
+	mp := NewMsgpack()
+
+	_, err := mp.Set(editInputBytes, []interface{}{"user", "tags", 2}, "z")
+	require.ErrorIs(t, err, ErrPathNotFound)
+
+	_, err = mp.Set(editInputBytes, []interface{}{"missing", "name"}, "z")
+	require.ErrorIs(t, err, ErrPathNotFound)
+}
+
+func TestDelete(t *testing.T) {
+	mp := NewMsgpack()
+
+	output, err := mp.Delete(editInputBytes, []interface{}{"user", "email"})
+	require.NoError(t, err)
+
+	jsonObj, err := mp.Unmarshal(output)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"v":    1,
+		"user": map[string]interface{}{"tags": []interface{}{"x", "y"}},
+	}, jsonObj)
+
+	output, err = mp.Delete(editInputBytes, []interface{}{"user", "tags", 0})
+	require.NoError(t, err)
+
+	tags, err := mp.Get(output, "user", "tags")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"y"}, tags)
+
+	_, err = mp.Delete(editInputBytes, []interface{}{"user", "name"})
+	require.ErrorIs(t, err, ErrPathNotFound)
+}
@@ -0,0 +1,76 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Extension writes the values of one Go type as ext values of one type code and reads them back.
+type Extension struct {
+	// Type is the Go type the extension handles, values of other types, even those it converts to, are not affected.
+	Type reflect.Type
+	// Code is the ext type of the encoded values.
+	Code int8
+	// Encode returns the ext data of v, a non-nil value of Type.
+	Encode func(v interface{}) ([]byte, error)
+	// Decode returns the value of Type held by data.
+	Decode func(data []byte) (interface{}, error)
+}
+
+// WithExtensions registers exts, each replacing an earlier extension of the same Go type or code.
+// Values of their Go types are written as ext values, a nil pointer, map or slice as nil,
+// and ext values of their codes decode into their Go types, also into interface{} values.
+func WithExtensions(exts ...Extension) Option {
+	return func(m *Msgpack) {
+		if m.extByType == nil {
+			m.extByType = make(map[reflect.Type]*Extension)
+			m.extByCode = make(map[int8]*Extension)
+		}
+		for i := range exts {
+			ext := exts[i]
+			if prev, ok := m.extByCode[ext.Code]; ok {
+				delete(m.extByType, prev.Type)
+			}
+			if prev, ok := m.extByType[ext.Type]; ok {
+				delete(m.extByCode, prev.Code)
+			}
+			m.extByType[ext.Type] = &ext
+			m.extByCode[ext.Code] = &ext
+		}
+	}
+}
+
+// extensionFor returns the extension of the Go type t, or nil.
+func (m *Msgpack) extensionFor(t reflect.Type) *Extension {
+	if len(m.extByType) == 0 {
+		return nil
+	}
+	return m.extByType[t]
+}
+
+// encodeExtension writes v with ext.
+func (m *Msgpack) encodeExtension(result *[]byte, ext *Extension, v interface{}) error {
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if rv.IsNil() {
+			*result = AppendNil(*result)
+			return nil
+		}
+	}
+
+	data, err := ext.Encode(v)
+	if err != nil {
+		return fmt.Errorf("encoding %s: %w", ext.Type, err)
+	}
+	*result = AppendExt(*result, ext.Code, data)
+	return nil
+}
+
+// decodeExtension decodes the data of an ext value of the code of ext at offset.
+func decodeExtension(ext *Extension, data []byte, offset int) (interface{}, error) {
+	value, err := ext.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s at offset %d: %w", ext.Type, offset, err)
+	}
+	return value, nil
+}
@@ -0,0 +1,229 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sync"
+)
+
+// DefaultMaxFrameSize is the payload size allowed when FrameOptions.MaxFrameSize is zero.
+const DefaultMaxFrameSize = 16 << 20
+
+var (
+	// ErrFrameTooLarge is returned for a frame whose payload exceeds the maximum frame size.
+	ErrFrameTooLarge = errors.New("frame too large")
+	// ErrFrameChecksum is returned for a frame whose CRC32C trailer does not match its payload.
+	ErrFrameChecksum = errors.New("frame checksum mismatch")
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// FramePrefix selects how the payload length is written in front of each frame.
+type FramePrefix int
+
+const (
+	// FrameUvarint writes the length as an unsigned varint, see encoding/binary.
+	FrameUvarint FramePrefix = iota
+	// FrameFixed32 writes the length as a 4-byte big-endian integer.
+	FrameFixed32
+)
+
+// FrameOptions controls the framing of a FrameWriter and FrameReader, both ends have to use the same options.
+//
+// A frame is the length prefix, the payload and, with CRC set, the CRC32C (Castagnoli) checksum
+// of the payload as a 4-byte big-endian integer.
+type FrameOptions struct {
+	// Prefix is the encoding of the payload length.
+	Prefix FramePrefix
+	// CRC adds a checksum trailer to each frame.
+	CRC bool
+	// MaxFrameSize is the maximum payload size in bytes, DefaultMaxFrameSize when zero.
+	MaxFrameSize int
+}
+
+func (o *FrameOptions) maxFrameSize() int {
+	if o.MaxFrameSize > 0 {
+		return o.MaxFrameSize
+	}
+	return DefaultMaxFrameSize
+}
+
+func (o *FrameOptions) prefixSize() int {
+	if o.Prefix == FrameFixed32 {
+		return 4
+	}
+	return binary.MaxVarintLen64
+}
+
+// FrameWriter writes length-prefixed MessagePack messages to a stream such as a net.Conn.
+// It is safe for concurrent use, each frame is written with a single Write call.
+type FrameWriter struct {
+	m    *Msgpack
+	w    io.Writer
+	opts FrameOptions
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// NewFrameWriter returns a FrameWriter writing to w.
+func (m *Msgpack) NewFrameWriter(w io.Writer, opts FrameOptions) *FrameWriter {
+	return &FrameWriter{m: m, w: w, opts: opts}
+}
+
+// Encode writes the AppendMarshal output of v as one frame.
+func (fw *FrameWriter) Encode(v interface{}) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	// leave room for the longest prefix, the payload is encoded in place
+	prefixSize := fw.opts.prefixSize()
+	var prefix [binary.MaxVarintLen64]byte
+	buf, err := fw.m.AppendMarshal(append(fw.buf[:0], prefix[:prefixSize]...), v)
+	fw.buf = buf[:0]
+	if err != nil {
+		return err
+	}
+	return fw.writeFrame(buf, prefixSize)
+}
+
+// WriteFrame writes payload, usually the output of Marshal, as one frame.
+func (fw *FrameWriter) WriteFrame(payload []byte) error {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	prefixSize := fw.opts.prefixSize()
+	var prefix [binary.MaxVarintLen64]byte
+	buf := append(append(fw.buf[:0], prefix[:prefixSize]...), payload...)
+	fw.buf = buf[:0]
+	return fw.writeFrame(buf, prefixSize)
+}
+
+// writeFrame writes the payload in buf[prefixSize:] with its prefix and trailer.
+func (fw *FrameWriter) writeFrame(buf []byte, prefixSize int) error {
+	payload := buf[prefixSize:]
+	if len(payload) > fw.opts.maxFrameSize() || fw.opts.Prefix == FrameFixed32 && uint64(len(payload)) > math.MaxUint32 {
+		return fmt.Errorf("%w: %d > %d bytes", ErrFrameTooLarge, len(payload), fw.opts.maxFrameSize())
+	}
+
+	// add length prefix right in front of the payload
+	start := 0
+	if fw.opts.Prefix == FrameFixed32 {
+		binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	} else {
+		var prefix [binary.MaxVarintLen64]byte
+		n := binary.PutUvarint(prefix[:], uint64(len(payload)))
+		start = prefixSize - n
+		copy(buf[start:], prefix[:n])
+	}
+
+	// add checksum trailer
+	if fw.opts.CRC {
+		buf = binary.BigEndian.AppendUint32(buf, crc32.Checksum(payload, crc32c))
+		fw.buf = buf[:0]
+	}
+
+	_, err := fw.w.Write(buf[start:])
+	return err
+}
+
+// FrameReader reads the length-prefixed MessagePack messages written by a FrameWriter.
+// It is not safe for concurrent use.
+//
+// The stream cannot be resynchronized after a frame that is too large or corrupt,
+// so every error but io.EOF at a frame boundary is returned again by later calls.
+type FrameReader struct {
+	m    *Msgpack
+	r    *bufio.Reader
+	opts FrameOptions
+
+	buf []byte
+	err error
+}
+
+// NewFrameReader returns a FrameReader reading from r.
+func (m *Msgpack) NewFrameReader(r io.Reader, opts FrameOptions) *FrameReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &FrameReader{m: m, r: br, opts: opts}
+}
+
+// ReadFrame returns the payload of the next frame, which is only valid until the next call.
+// It returns io.EOF when the stream ends between frames and io.ErrUnexpectedEOF when it ends within one.
+func (fr *FrameReader) ReadFrame() ([]byte, error) {
+	if fr.err != nil {
+		return nil, fr.err
+	}
+
+	payload, err := fr.readFrame()
+	if err != nil && err != io.EOF {
+		fr.err = err
+	}
+	return payload, err
+}
+
+func (fr *FrameReader) readFrame() ([]byte, error) {
+	// read length prefix
+	var size uint64
+	if fr.opts.Prefix == FrameFixed32 {
+		var prefix [4]byte
+		if _, err := io.ReadFull(fr.r, prefix[:]); err != nil {
+			return nil, err
+		}
+		size = uint64(binary.BigEndian.Uint32(prefix[:]))
+	} else {
+		if _, err := fr.r.Peek(1); err != nil {
+			return nil, err
+		}
+		var err error
+		size, err = binary.ReadUvarint(fr.r)
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid frame length: %w", err)
+		}
+	}
+
+	if size > uint64(fr.opts.maxFrameSize()) {
+		return nil, fmt.Errorf("%w: %d > %d bytes", ErrFrameTooLarge, size, fr.opts.maxFrameSize())
+	}
+
+	// read payload and checksum trailer
+	n := int(size)
+	if fr.opts.CRC {
+		n += 4
+	}
+	if cap(fr.buf) < n {
+		fr.buf = make([]byte, n)
+	}
+	buf := fr.buf[:n]
+	if _, err := io.ReadFull(fr.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	payload := buf[:size]
+	if fr.opts.CRC && binary.BigEndian.Uint32(buf[size:]) != crc32.Checksum(payload, crc32c) {
+		return nil, ErrFrameChecksum
+	}
+	return payload, nil
+}
+
+// Decode reads the next frame and decodes its payload into the value pointed to by v with UnmarshalTo.
+func (fr *FrameReader) Decode(v interface{}) error {
+	payload, err := fr.ReadFrame()
+	if err != nil {
+		return err
+	}
+	return fr.m.UnmarshalTo(payload, v)
+}
@@ -0,0 +1,161 @@
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameEncoding(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		opts     FrameOptions
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - uvarint prefix",
+			opts:     FrameOptions{Prefix: FrameUvarint},
+			expected: []byte{0x04, 0x81, 0xa1, 0x61, 0x01},
+		},
+		{
+			desc:     "Test Case - fixed 4-byte prefix",
+			opts:     FrameOptions{Prefix: FrameFixed32},
+			expected: []byte{0x00, 0x00, 0x00, 0x04, 0x81, 0xa1, 0x61, 0x01},
+		},
+		{
+			desc:     "Test Case - CRC32C trailer",
+			opts:     FrameOptions{CRC: true},
+			expected: []byte{0x04, 0x81, 0xa1, 0x61, 0x01, 0x69, 0xf5, 0x08, 0x1b},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, mp.NewFrameWriter(&buf, tc.opts).Encode(map[string]interface{}{"a": 1}))
+			require.Equal(t, tc.expected, buf.Bytes())
+
+			payload, err := mp.NewFrameReader(&buf, tc.opts).ReadFrame()
+			require.NoError(t, err)
+			require.Equal(t, []byte{0x81, 0xa1, 0x61, 0x01}, payload)
+		})
+	}
+}
+
+func TestFramePipe(t *testing.T) {
+	mp := NewMsgpack()
+
+	type message struct {
+		ID   int    `msgpack:"id"`
+		Body string `msgpack:"body"`
+	}
+
+	for _, opts := range []FrameOptions{
+		{Prefix: FrameUvarint},
+		{Prefix: FrameFixed32, CRC: true},
+	} {
+		client, server := net.Pipe()
+
+		go func() {
+			defer client.Close()
+			fw := mp.NewFrameWriter(client, opts)
+			for i := 0; i < 100; i++ {
+				if err := fw.Encode(message{ID: i, Body: string(bytes.Repeat([]byte{'x'}, i*10))}); err != nil {
+					return
+				}
+			}
+		}()
+
+		fr := mp.NewFrameReader(server, opts)
+		for i := 0; i < 100; i++ {
+			var msg message
+			require.NoError(t, fr.Decode(&msg))
+			require.Equal(t, i, msg.ID)
+			require.Len(t, msg.Body, i*10)
+		}
+
+		// the writer closed the connection between frames
+		var msg message
+		require.Equal(t, io.EOF, fr.Decode(&msg))
+		server.Close()
+	}
+}
+
+func TestFrameErrors(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc  string
+		opts  FrameOptions
+		input []byte
+		err   error
+	}{
+		{
+			desc:  "Test Case - oversized frame",
+			opts:  FrameOptions{MaxFrameSize: 3},
+			input: []byte{0x04, 0x81, 0xa1, 0x61, 0x01},
+			err:   ErrFrameTooLarge,
+		},
+		{
+			desc:  "Test Case - oversized fixed prefix",
+			opts:  FrameOptions{Prefix: FrameFixed32},
+			input: []byte{0xff, 0xff, 0xff, 0xff},
+			err:   ErrFrameTooLarge,
+		},
+		{
+			desc:  "Test Case - corrupt payload",
+			opts:  FrameOptions{CRC: true},
+			input: []byte{0x04, 0x81, 0xa1, 0x62, 0x01, 0x69, 0xf5, 0x08, 0x1b},
+			err:   ErrFrameChecksum,
+		},
+		{
+			desc:  "Test Case - truncated payload",
+			input: []byte{0x04, 0x81, 0xa1},
+			err:   io.ErrUnexpectedEOF,
+		},
+		{
+			desc:  "Test Case - truncated prefix",
+			opts:  FrameOptions{Prefix: FrameFixed32},
+			input: []byte{0x00, 0x00},
+			err:   io.ErrUnexpectedEOF,
+		},
+		{
+			desc:  "Test Case - truncated uvarint prefix",
+			input: []byte{0x80},
+			err:   io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fr := mp.NewFrameReader(bytes.NewReader(tc.input), tc.opts)
+
+			_, err := fr.ReadFrame()
+			require.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+
+			// the stream is not read any further
+			_, err = fr.ReadFrame()
+			require.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+		})
+	}
+}
+
+func TestFrameWriterTooLarge(t *testing.T) {
+	mp := NewMsgpack()
+
+	var buf bytes.Buffer
+	fw := mp.NewFrameWriter(&buf, FrameOptions{MaxFrameSize: 3})
+
+	err := fw.WriteFrame([]byte{0x81, 0xa1, 0x61, 0x01})
+	require.True(t, errors.Is(err, ErrFrameTooLarge), "got %v", err)
+	require.Zero(t, buf.Len())
+
+	require.NoError(t, fw.WriteFrame([]byte{0x01}))
+	require.Equal(t, []byte{0x01, 0x01}, buf.Bytes())
+}
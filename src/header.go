@@ -0,0 +1,103 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	MsgPackTypes "msgpack/src/types"
+)
+
+// readLength reads a big-endian unsigned length of size bytes at data[*i].
+// Lengths larger than the input after the header are rejected before they are converted to int,
+// where a 32-bit length would turn negative on 32-bit platforms.
+func readLength(data []byte, i *int, size int) (int, error) {
+	if *i+size > len(data) {
+		return 0, fmt.Errorf("data out of range")
+	}
+
+	var length uint64
+	switch size {
+	case 1:
+		length = uint64(data[*i])
+	case 2:
+		length = uint64(binary.BigEndian.Uint16(data[*i:]))
+	case 4:
+		length = uint64(binary.BigEndian.Uint32(data[*i:]))
+	}
+
+	// every byte, element or entry takes at least one byte of the remaining input
+	if remaining := uint64(len(data) - *i - size); length > remaining {
+		return 0, fmt.Errorf("insufficient data for length %d at offset %d", length, *i)
+	}
+	*i += size
+
+	return int(length), nil
+}
+
+// readStringHeader parses a str header at data[*i] and returns the length of the string.
+func readStringHeader(data []byte, i *int) (int, error) {
+	if *i >= len(data) {
+		return 0, fmt.Errorf("data out of range")
+	}
+
+	currentByte := data[*i]
+	*i++
+
+	switch {
+	case MsgPackTypes.IsMsgPackTypeString(currentByte):
+		return int(currentByte & 0x1F), nil // 0x1F = 00011111
+	case currentByte == MsgPackTypes.Str8:
+		return readLength(data, i, 1)
+	case currentByte == MsgPackTypes.Str16:
+		return readLength(data, i, 2)
+	case currentByte == MsgPackTypes.Str32:
+		return readLength(data, i, 4)
+	}
+
+	*i--
+	return 0, fmt.Errorf("expected string at offset %d, got 0x%02x", *i, currentByte)
+}
+
+// readArrayHeader parses an array header at data[*i] and returns the number of elements.
+func readArrayHeader(data []byte, i *int) (int, error) {
+	if *i >= len(data) {
+		return 0, fmt.Errorf("data out of range")
+	}
+
+	currentByte := data[*i]
+	*i++
+
+	switch {
+	case MsgPackTypes.IsMsgPackTypeArray(currentByte):
+		return int(currentByte & 0x0F), nil // 0x0F = 00001111
+	case currentByte == MsgPackTypes.Array16:
+		return readLength(data, i, 2)
+	case currentByte == MsgPackTypes.Array32:
+		return readLength(data, i, 4)
+	}
+
+	*i--
+	return 0, fmt.Errorf("expected array at offset %d, got 0x%02x", *i, currentByte)
+}
+
+// readMapHeader parses a map header at data[*i] and returns the number of key-value pairs.
+func readMapHeader(data []byte, i *int) (int, error) {
+	if *i >= len(data) {
+		return 0, fmt.Errorf("data out of range")
+	}
+
+	currentByte := data[*i]
+	*i++
+
+	switch {
+	case MsgPackTypes.IsMsgPackTypeMap(currentByte):
+		return int(currentByte & 0x0F), nil // 0x0F = 00001111
+	case currentByte == MsgPackTypes.Map16:
+		return readLength(data, i, 2)
+	case currentByte == MsgPackTypes.Map32:
+		return readLength(data, i, 4)
+	}
+
+	*i--
+	return 0, fmt.Errorf("expected map at offset %d, got 0x%02x", *i, currentByte)
+}
@@ -0,0 +1,78 @@
+package msgpack
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalLegacyRaw(t *testing.T) {
+	mp := NewMsgpack()
+
+	long := string(bytes.Repeat([]byte{0x61}, 40))
+
+	testCases := []struct {
+		desc     string
+		jsonObj  map[string]interface{}
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - fixraw",
+			jsonObj:  map[string]interface{}{"a": "asd"},
+			expected: []byte{0x81, 0xa1, 0x61, 0xa3, 0x61, 0x73, 0x64},
+		},
+		{
+			desc:     "Test Case - raw16 instead of str8",
+			jsonObj:  map[string]interface{}{"a": long},
+			expected: append([]byte{0x81, 0xa1, 0x61, 0xda, 0x00, 0x28}, long...),
+		},
+		{
+			desc:     "Test Case - raw instead of bin",
+			jsonObj:  map[string]interface{}{"a": []byte{0x01, 0x02}},
+			expected: []byte{0x81, 0xa1, 0x61, 0xa2, 0x01, 0x02},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m1, err := mp.MarshalWithOptions(tc.jsonObj, EncodeOptions{LegacyRaw: true})
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, m1, "The two MessagePack byte should be equal")
+		})
+	}
+}
+
+func TestUnmarshalLegacyRaw(t *testing.T) {
+	mp := NewMsgpack()
+
+	// {"a": raw "asd", "b": raw 0x01 0x02}
+	inputBytes := []byte{0x82, 0xa1, 0x61, 0xa3, 0x61, 0x73, 0x64, 0xa1, 0x62, 0xa2, 0x01, 0x02}
+
+	output, err := mp.UnmarshalWithOptions(inputBytes, DecodeOptions{LegacyRaw: true})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"a": []byte("asd"),
+		"b": []byte{0x01, 0x02},
+	}, output)
+
+	var asStrings map[string]string
+	require.NoError(t, mp.UnmarshalToWithOptions(inputBytes, &asStrings, DecodeOptions{LegacyRaw: true}))
+	require.Equal(t, "asd", asStrings["a"])
+
+	var asBytes map[string][]byte
+	require.NoError(t, mp.UnmarshalToWithOptions(inputBytes, &asBytes, DecodeOptions{LegacyRaw: true}))
+	require.Equal(t, []byte{0x01, 0x02}, asBytes["b"])
+}
+
+func TestLegacyRawRoundTrip(t *testing.T) {
+	mp := NewMsgpack()
+
+	m1, err := mp.MarshalWithOptions(map[string]interface{}{"a": []byte{0xff}}, EncodeOptions{LegacyRaw: true})
+	require.NoError(t, err)
+
+	output, err := mp.UnmarshalWithOptions(m1, DecodeOptions{LegacyRaw: true})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": []byte{0xff}}, output)
+}
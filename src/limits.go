@@ -0,0 +1,85 @@
+package msgpack
+
+import "fmt"
+
+// DefaultMaxDepth is the nesting depth allowed when DecodeOptions.MaxDepth is zero.
+const DefaultMaxDepth = 10000
+
+// LimitError is returned when decoding exceeds one of the DecodeOptions limits.
+type LimitError struct {
+	Limit  string // name of the exceeded DecodeOptions field
+	Value  int    // value claimed or reached by the input
+	Max    int
+	Offset int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s exceeded at offset %d: %d > %d", e.Limit, e.Offset, e.Value, e.Max)
+}
+
+// approximate sizes of decoded generic values
+const (
+	interfaceSize = 16
+	mapEntrySize  = 32
+)
+
+// checkLimits rejects a token that exceeds the DecodeOptions of r
+// or claims more elements than the remaining input can hold.
+func (r *Reader) checkLimits(tok *Token, offset int) error {
+	switch tok.Kind {
+	case StrKind:
+		if r.opts.MaxStringLen > 0 && len(tok.Bytes) > r.opts.MaxStringLen {
+			return &LimitError{Limit: "MaxStringLen", Value: len(tok.Bytes), Max: r.opts.MaxStringLen, Offset: offset}
+		}
+
+	case BinKind, ExtKind:
+		if r.opts.MaxBinLen > 0 && len(tok.Bytes) > r.opts.MaxBinLen {
+			return &LimitError{Limit: "MaxBinLen", Value: len(tok.Bytes), Max: r.opts.MaxBinLen, Offset: offset}
+		}
+
+	case ArrayKind, MapKind:
+		if r.opts.MaxContainerLen > 0 && tok.Length > r.opts.MaxContainerLen {
+			return &LimitError{Limit: "MaxContainerLen", Value: tok.Length, Max: r.opts.MaxContainerLen, Offset: offset}
+		}
+
+		// every element takes at least one byte, every map entry two
+		maxLen := len(r.data) - r.offset
+		if tok.Kind == MapKind {
+			maxLen /= 2
+		}
+		if tok.Length > maxLen {
+			return fmt.Errorf("insufficient data for %s of length %d at offset %d", tok.Kind, tok.Length, offset)
+		}
+	}
+
+	return nil
+}
+
+// enter records that decoding goes one container deeper.
+func (r *Reader) enter() error {
+	r.depth++
+
+	maxDepth := r.opts.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	if r.depth > maxDepth {
+		return &LimitError{Limit: "MaxDepth", Value: r.depth, Max: maxDepth, Offset: r.offset}
+	}
+	return nil
+}
+
+// leave records that decoding of a container has finished.
+func (r *Reader) leave() {
+	r.depth--
+}
+
+// allocate charges n bytes against the MaxAllocBytes budget.
+func (r *Reader) allocate(n int) error {
+	r.allocated += n
+
+	if r.opts.MaxAllocBytes > 0 && r.allocated > r.opts.MaxAllocBytes {
+		return &LimitError{Limit: "MaxAllocBytes", Value: r.allocated, Max: r.opts.MaxAllocBytes, Offset: r.offset}
+	}
+	return nil
+}
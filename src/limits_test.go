@@ -0,0 +1,143 @@
+package msgpack
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeLimits(t *testing.T) {
+	mp := NewMsgpack()
+
+	nested := append(bytes.Repeat([]byte{0x81, 0xa1, 0x61}, 5), 0xc0)
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		opts       DecodeOptions
+		limit      string
+	}{
+		{
+			desc:       "Test Case - MaxDepth",
+			inputBytes: nested,
+			opts:       DecodeOptions{MaxDepth: 4},
+			limit:      "MaxDepth",
+		},
+		{
+			desc:       "Test Case - default MaxDepth",
+			inputBytes: append(bytes.Repeat([]byte{0x81, 0xa1, 0x61}, DefaultMaxDepth+1), 0xc0),
+			limit:      "MaxDepth",
+		},
+		{
+			desc:       "Test Case - MaxContainerLen",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0x93, 0x01, 0x02, 0x03},
+			opts:       DecodeOptions{MaxContainerLen: 2},
+			limit:      "MaxContainerLen",
+		},
+		{
+			desc:       "Test Case - MaxStringLen",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xa3, 0x61, 0x73, 0x64},
+			opts:       DecodeOptions{MaxStringLen: 2},
+			limit:      "MaxStringLen",
+		},
+		{
+			desc:       "Test Case - MaxBinLen",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xc4, 0x03, 0x01, 0x02, 0x03},
+			opts:       DecodeOptions{MaxBinLen: 2},
+			limit:      "MaxBinLen",
+		},
+		{
+			desc:       "Test Case - MaxBinLen on ext",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xd6, 0x01, 0x00, 0x00, 0x00, 0x00},
+			opts:       DecodeOptions{MaxBinLen: 2},
+			limit:      "MaxBinLen",
+		},
+		{
+			desc:       "Test Case - MaxAllocBytes",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0x93, 0xa1, 0x61, 0xa1, 0x62, 0xa1, 0x63},
+			opts:       DecodeOptions{MaxAllocBytes: 40},
+			limit:      "MaxAllocBytes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := mp.UnmarshalWithOptions(tc.inputBytes, tc.opts)
+
+			var limitErr *LimitError
+			require.True(t, errors.As(err, &limitErr), "expected a *LimitError, got %v", err)
+			require.Equal(t, tc.limit, limitErr.Limit)
+
+			var v map[string]interface{}
+			err = mp.UnmarshalToWithOptions(tc.inputBytes, &v, tc.opts)
+			require.True(t, errors.As(err, &limitErr), "expected a *LimitError, got %v", err)
+			require.Equal(t, tc.limit, limitErr.Limit)
+		})
+	}
+}
+
+func TestDecodeWithinLimits(t *testing.T) {
+	mp := NewMsgpack()
+
+	opts := DecodeOptions{MaxDepth: 2, MaxContainerLen: 3, MaxStringLen: 3, MaxBinLen: 3, MaxAllocBytes: 1024}
+	output, err := mp.UnmarshalWithOptions([]byte{0x81, 0xa1, 0x61, 0x93, 0xa1, 0x61, 0xa1, 0x62, 0xa1, 0x63}, opts)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": []interface{}{"a", "b", "c"}}, output)
+}
+
+func TestDecodeClaimedLengthBeyondInput(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+	}{
+		{
+			desc:       "Test Case - array32",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xdd, 0xff, 0xff, 0xff, 0xff, 0xc0},
+		},
+		{
+			desc:       "Test Case - map32",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xdf, 0xff, 0xff, 0xff, 0xff, 0xa1, 0x62, 0xc0},
+		},
+		{
+			desc:       "Test Case - str32",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xdb, 0xff, 0xff, 0xff, 0xff, 0x61},
+		},
+		// lengths of 2^31 and more do not fit an int on 32-bit platforms
+		{
+			desc:       "Test Case - array32 of 2^31 elements",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xdd, 0x80, 0x00, 0x00, 0x00, 0xc0},
+		},
+		{
+			desc:       "Test Case - map32 of 2^31 entries",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xdf, 0x80, 0x00, 0x00, 0x01, 0xa1, 0x62, 0xc0},
+		},
+		{
+			desc:       "Test Case - bin32",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xc6, 0x80, 0x00, 0x00, 0x00, 0x01},
+		},
+		{
+			desc:       "Test Case - ext32",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xc9, 0xff, 0xff, 0xff, 0xff, 0x01, 0x02},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			allocs := testing.AllocsPerRun(10, func() {
+				_, err := mp.Unmarshal(tc.inputBytes)
+				require.Error(t, err)
+			})
+			require.Less(t, allocs, float64(10))
+
+			var v map[string][]RawMessage
+			require.Error(t, mp.UnmarshalTo(tc.inputBytes, &v))
+
+			_, err := Skip(tc.inputBytes, 0)
+			require.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrPathNotFound is returned by Get when a map key or array index of the path does not exist.
+var ErrPathNotFound = errors.New("path not found")
+
+// Get returns the value at path without decoding the rest of data.
+// A string path element selects a map key and an int selects an array index,
+// values that are not on the path are skipped in place.
+func (m *Msgpack) Get(data []byte, path ...interface{}) (interface{}, error) {
+	var i int
+	if err := lookup(data, &i, path); err != nil {
+		return nil, err
+	}
+
+	r := Reader{data: data, offset: i, opts: m.decodeOpts}
+	return m.decodeMsgpack(&r)
+}
+
+// GetString returns the string at path.
+func (m *Msgpack) GetString(data []byte, path ...interface{}) (string, error) {
+	var s string
+	err := m.getAs(data, path, &s)
+	return s, err
+}
+
+// GetInt returns the integer at path, any integer encoding that fits in an int64 is accepted.
+func (m *Msgpack) GetInt(data []byte, path ...interface{}) (int64, error) {
+	var n int64
+	err := m.getAs(data, path, &n)
+	return n, err
+}
+
+// GetUint returns the unsigned integer at path.
+func (m *Msgpack) GetUint(data []byte, path ...interface{}) (uint64, error) {
+	var n uint64
+	err := m.getAs(data, path, &n)
+	return n, err
+}
+
+// GetFloat returns the number at path as a float64.
+func (m *Msgpack) GetFloat(data []byte, path ...interface{}) (float64, error) {
+	var f float64
+	err := m.getAs(data, path, &f)
+	return f, err
+}
+
+// GetBool returns the boolean at path.
+func (m *Msgpack) GetBool(data []byte, path ...interface{}) (bool, error) {
+	var b bool
+	err := m.getAs(data, path, &b)
+	return b, err
+}
+
+func (m *Msgpack) getAs(data []byte, path []interface{}, v interface{}) error {
+	value, err := m.Get(data, path...)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return fmt.Errorf("value at path %v is nil", path)
+	}
+	return assignValue(reflect.ValueOf(v).Elem(), value)
+}
+
+// lookup moves i to the start of the value found at path.
+func lookup(data []byte, i *int, path []interface{}) error {
+	for _, p := range path {
+		switch key := p.(type) {
+		case string:
+			mapLen, err := readMapHeader(data, i)
+			if err != nil {
+				return err
+			}
+
+			found := false
+			for j := 0; j < mapLen; j++ {
+				strLen, err := readStringHeader(data, i)
+				if err != nil {
+					return err
+				}
+				if *i+strLen > len(data) {
+					return fmt.Errorf("insufficient data for string of length %d", strLen)
+				}
+
+				// compare the key in place
+				match := string(data[*i:*i+strLen]) == key
+				*i += strLen
+
+				if match {
+					found = true
+					break
+				}
+				if *i, err = Skip(data, *i); err != nil {
+					return err
+				}
+			}
+			if !found {
+				return fmt.Errorf("%w: key %q", ErrPathNotFound, key)
+			}
+
+		case int:
+			arrLen, err := readArrayHeader(data, i)
+			if err != nil {
+				return err
+			}
+			if key < 0 || key >= arrLen {
+				return fmt.Errorf("%w: index %d", ErrPathNotFound, key)
+			}
+
+			for j := 0; j < key; j++ {
+				if *i, err = Skip(data, *i); err != nil {
+					return err
+				}
+			}
+
+		default:
+			return fmt.Errorf("unsupported path element of type %T", p)
+		}
+	}
+
+	return nil
+}
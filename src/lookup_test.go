@@ -0,0 +1,89 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// {"id": 300, "blob": <bin 0x01 0x02>, "user": {"name": "Ann", "tags": ["x", "y"]}}
+var lookupInputBytes = []byte{
+	0x83,
+	0xa2, 0x69, 0x64, 0xcd, 0x01, 0x2c,
+	0xa4, 0x62, 0x6c, 0x6f, 0x62, 0xc4, 0x02, 0x01, 0x02,
+	0xa4, 0x75, 0x73, 0x65, 0x72, 0x82,
+	0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa3, 0x41, 0x6e, 0x6e,
+	0xa4, 0x74, 0x61, 0x67, 0x73, 0x92, 0xa1, 0x78, 0xa1, 0x79,
+}
+
+func TestGet(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		path     []interface{}
+		expected interface{}
+		notFound bool
+	}{
+		{
+			desc:     "Test Case - top level key",
+			path:     []interface{}{"id"},
+			expected: uint16(300),
+		},
+		{
+			desc:     "Test Case - nested key",
+			path:     []interface{}{"user", "name"},
+			expected: "Ann",
+		},
+		{
+			desc:     "Test Case - array index",
+			path:     []interface{}{"user", "tags", 1},
+			expected: "y",
+		},
+		{
+			desc:     "Test Case - whole container",
+			path:     []interface{}{"user", "tags"},
+			expected: []interface{}{"x", "y"},
+		},
+		{
+			desc:     "Test Case - missing key",
+			path:     []interface{}{"user", "email"},
+			notFound: true,
+		},
+		{
+			desc:     "Test Case - index out of range",
+			path:     []interface{}{"user", "tags", 2},
+			notFound: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			value, err := mp.Get(lookupInputBytes, tc.path...)
+			if tc.notFound {
+				require.ErrorIs(t, err, ErrPathNotFound)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestGetTyped(t *testing.T) {
+	mp := NewMsgpack()
+
+	id, err := mp.GetInt(lookupInputBytes, "id")
+	require.NoError(t, err)
+	require.Equal(t, int64(300), id)
+
+	name, err := mp.GetString(lookupInputBytes, "user", "name")
+	require.NoError(t, err)
+	require.Equal(t, "Ann", name)
+
+	_, err = mp.GetString(lookupInputBytes, "id")
+	require.Error(t, err)
+
+	_, err = mp.GetInt(lookupInputBytes, "user", 0)
+	require.Error(t, err)
+}
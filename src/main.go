@@ -1,9 +1,39 @@
 package msgpack
 
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
 // Msgpack is a class that converts data from JSON format to MessagePack format and vice versa.
-type Msgpack struct{}
+// Its options are fixed by NewMsgpack and a Msgpack is safe for concurrent use by multiple goroutines,
+// only RegisterName changes it later, see there.
+type Msgpack struct {
+	tagName    string
+	encodeOpts EncodeOptions
+	decodeOpts DecodeOptions
+
+	// plans caches the *structPlan of each struct type, see structPlanFor
+	plans sync.Map
+
+	// registered types, see RegisterName
+	typeNames   sync.Map // reflect.Type to name
+	namedTypes  sync.Map // name to reflect.Type
+	registered  atomic.Bool
+	typeExt     bool
+	typeExtCode int8
+
+	// extensions, see WithExtensions
+	extByType map[reflect.Type]*Extension
+	extByCode map[int8]*Extension
+}
 
-// NewMsgpack returns a new instance of the Msgpack class.
-func NewMsgpack() *Msgpack {
-	return &Msgpack{}
+// NewMsgpack returns a new instance of the Msgpack class configured by opts.
+func NewMsgpack(opts ...Option) *Msgpack {
+	m := &Msgpack{tagName: DefaultTagName}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
@@ -1,187 +1,422 @@
 package msgpack
 
 import (
-	"bytes"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
-	"math"
+	"math/big"
 	"reflect"
+	"sort"
 	"strconv"
-
-	MsgPackTypes "msgpack/src/types"
+	"strings"
+	"sync"
+	"time"
 )
 
+// maxPooledBufferSize keeps unusually large buffers out of the pool.
+const maxPooledBufferSize = 64 << 10
+
+// bufferPool holds encoder buffers shared by Marshal calls.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+// startDetectingCyclesAfter is the pointer depth from which encoding checks for cycles,
+// shallower values are not worth the bookkeeping.
+const startDetectingCyclesAfter = 1000
+
+// UnsupportedTypeError is returned when encoding a value of a type that has no MessagePack form,
+// such as channels, functions, complex numbers without an extension and maps with non-string keys.
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "unsupported type " + e.Type.String()
+}
+
+// encodeState holds the options and the state of a single encoding.
+type encodeState struct {
+	EncodeOptions
+
+	// pointers, maps and slices being followed, to report cycles instead of recursing forever
+	ptrLevel int
+	ptrSeen  map[ptrKey]struct{}
+}
+
+// ptrKey identifies a pointer or map, or the elements of a slice by their address and number.
+type ptrKey struct {
+	ptr uintptr
+	len int
+}
+
+// cycleKey returns the ptrKey of the pointer, map or slice v.
+func cycleKey(v reflect.Value) ptrKey {
+	if v.Kind() == reflect.Slice {
+		return ptrKey{ptr: v.Pointer(), len: v.Len()}
+	}
+	return ptrKey{ptr: v.Pointer(), len: -1}
+}
+
+// enter records that encoding follows the non-nil pointer, map or slice v,
+// once deep enough it reports v being followed already as a cycle.
+func (s *encodeState) enter(v reflect.Value) error {
+	s.ptrLevel++
+	if s.ptrLevel <= startDetectingCyclesAfter {
+		return nil
+	}
+
+	key := cycleKey(v)
+	if _, ok := s.ptrSeen[key]; ok {
+		s.ptrLevel--
+		return fmt.Errorf("encountered a cycle via %s", v.Type())
+	}
+	if s.ptrSeen == nil {
+		s.ptrSeen = make(map[ptrKey]struct{})
+	}
+	s.ptrSeen[key] = struct{}{}
+	return nil
+}
+
+// leave records that encoding of v, entered before, has finished.
+func (s *encodeState) leave(v reflect.Value) {
+	if s.ptrLevel > startDetectingCyclesAfter {
+		delete(s.ptrSeen, cycleKey(v))
+	}
+	s.ptrLevel--
+}
+
 // Marshal
 func (m *Msgpack) Marshal(data map[string]interface{}) ([]byte, error) {
-	msg, err := m.encodeJSON(data)
+	return m.MarshalWithOptions(data, m.encodeOpts)
+}
+
+// MarshalWithOptions is like Marshal but writes the values according to opts,
+// which replace the encode options of m.
+func (m *Msgpack) MarshalWithOptions(data map[string]interface{}, opts EncodeOptions) ([]byte, error) {
+	msg, err := m.encodeJSON(data, &opts)
 	if err != nil {
 		return nil, err
 	}
 	return msg, nil
 }
 
-func (m *Msgpack) encodeJSON(data map[string]interface{}) ([]byte, error) {
-	var result []byte
-	m.handleValue(&result, data)
+// AppendMarshal appends the MessagePack encoding of v to dst and returns the extended buffer.
+// It does not allocate when dst has enough capacity and v holds only maps, slices and primitives.
+func (m *Msgpack) AppendMarshal(dst []byte, v interface{}) ([]byte, error) {
+	return m.AppendMarshalWithOptions(dst, v, m.encodeOpts)
+}
+
+// AppendMarshalWithOptions is like AppendMarshal but writes the values according to opts,
+// which replace the encode options of m.
+func (m *Msgpack) AppendMarshalWithOptions(dst []byte, v interface{}, opts EncodeOptions) ([]byte, error) {
+	result := dst
+	if err := m.handleValue(&result, v, &encodeState{EncodeOptions: opts}); err != nil {
+		return dst, err
+	}
+	return result, nil
+}
+
+// encodeJSON encodes data into a pooled buffer and returns a copy of the result.
+func (m *Msgpack) encodeJSON(data map[string]interface{}, opts *EncodeOptions) ([]byte, error) {
+	bufp := bufferPool.Get().(*[]byte)
+
+	buf, err := m.AppendMarshalWithOptions((*bufp)[:0], data, *opts)
+	if err != nil {
+		bufferPool.Put(bufp)
+		return nil, err
+	}
+
+	result := make([]byte, len(buf))
+	copy(result, buf)
+
+	if cap(buf) <= maxPooledBufferSize {
+		*bufp = buf
+		bufferPool.Put(bufp)
+	}
 	return result, nil
 }
 
-func (m *Msgpack) handleValue(result *[]byte, data interface{}) error {
-	switch v := reflect.ValueOf(data); v.Kind() {
+func (m *Msgpack) handleValue(result *[]byte, data interface{}, opts *encodeState) error {
+	if ext := m.extensionFor(reflect.TypeOf(data)); ext != nil {
+		return m.encodeExtension(result, ext, data)
+	}
 
-	case reflect.Bool:
-		// add value
-		if v.Bool() {
-			*result = append(*result, byte(MsgPackTypes.True))
-		} else {
-			*result = append(*result, byte(MsgPackTypes.False))
+	// common values are encoded without going through reflection
+	switch v := data.(type) {
+	case nil:
+		*result = AppendNil(*result)
+		return nil
+
+	case bool:
+		*result = AppendBool(*result, v)
+		return nil
+
+	case float64:
+		encodeFloat64(result, v, opts)
+		return nil
+
+	case string:
+		return m.encodeString(result, v, opts)
+
+	case json.Number:
+		_, err := m.encodeMsgPackTypeNumberFamily(result, v, opts)
+		return err
+
+	case *big.Int:
+		if v == nil {
+			*result = AppendNil(*result)
+			return nil
 		}
+		*result = appendBigInt(*result, v)
+		return nil
 
-	case reflect.Ptr, reflect.Invalid:
-		// add value
-		*result = append(*result, byte(MsgPackTypes.Nil))
+	case big.Int:
+		*result = appendBigInt(*result, &v)
+		return nil
+
+	case *big.Float:
+		if v == nil {
+			*result = AppendNil(*result)
+			return nil
+		}
+		*result = appendBigFloat(*result, v)
+		return nil
+
+	case big.Float:
+		*result = appendBigFloat(*result, &v)
+		return nil
+
+	case time.Time:
+		*result = appendTime(*result, v)
+		return nil
+
+	case RawMessage:
+		return m.encodeRawMessage(result, v)
+
+	case []byte:
+		m.encodeBytes(result, v, opts)
+		return nil
+
+	case Ext:
+		*result = AppendExt(*result, v.Type, v.Data)
+		return nil
+
+	case []interface{}:
+		if v != nil {
+			rv := reflect.ValueOf(data)
+			if err := opts.enter(rv); err != nil {
+				return err
+			}
+			defer opts.leave(rv)
+		}
 
-	case reflect.Float64:
 		// add type prefix
-		*result = append(*result, byte(MsgPackTypes.Float64))
+		*result = AppendArrayHeader(*result, len(v))
 
 		// add value
-		var buf bytes.Buffer
+		for _, elem := range v {
+			if err := m.encodeInterface(result, elem, opts); err != nil {
+				return err
+			}
+		}
+		return nil
 
-		err := binary.Write(&buf, binary.BigEndian, v.Float())
-		if err != nil {
-			fmt.Println("binary.Write failed:", err)
+	case map[string]interface{}:
+		if v != nil {
+			rv := reflect.ValueOf(data)
+			if err := opts.enter(rv); err != nil {
+				return err
+			}
+			defer opts.leave(rv)
 		}
-		*result = append(*result, buf.Bytes()...)
 
-	case reflect.String:
-		//
-		if num, ok := data.(json.Number); ok {
-			// as number case
-			if _, err := m.encodeMsgPackTypeNumberFamily(result, num); err != nil {
+		// add type prefix
+		*result = AppendMapHeader(*result, len(v))
+
+		if opts.SortMapKeys {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+
+			// add value
+			for _, key := range keys {
+				if err := m.encodeMapKey(result, key, opts); err != nil {
+					return err
+				}
+				if err := m.encodeInterface(result, v[key], opts); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		// add value
+		for key, elem := range v {
+			if err := m.encodeMapKey(result, key, opts); err != nil {
+				return err
+			}
+			if err := m.encodeInterface(result, elem, opts); err != nil {
 				return err
 			}
+		}
+		return nil
+	}
 
-		} else {
-			// as string case
+	switch v := reflect.ValueOf(data); v.Kind() {
 
-			valueAsBytes := []byte(v.String())
+	case reflect.Bool:
+		*result = AppendBool(*result, v.Bool())
 
-			// add type prefix
-			*result = append(*result, byte(len(valueAsBytes))+MsgPackTypes.FixStr)
+	case reflect.Invalid:
+		// add value
+		*result = AppendNil(*result)
 
-			// add value
-			*result = append(*result, valueAsBytes...)
+	case reflect.Ptr:
+		if v.IsNil() {
+			*result = AppendNil(*result)
+			break
 		}
+		return m.encodePointer(result, v, opts)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		*result = AppendInt(*result, v.Int())
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		*result = AppendUint(*result, v.Uint())
+
+	case reflect.Float32:
+		*result = AppendFloat32(*result, float32(v.Float()))
+
+	case reflect.Float64:
+		encodeFloat64(result, v.Float(), opts)
+
+	case reflect.String:
+		return m.encodeString(result, v.String(), opts)
 
 	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			m.encodeBytes(result, v.Bytes(), opts)
+			break
+		}
+
+		if v.Kind() == reflect.Slice && !v.IsNil() {
+			if err := opts.enter(v); err != nil {
+				return err
+			}
+			defer opts.leave(v)
+		}
+
 		// add type prefix
-		*result = append(*result, byte(v.Len())+MsgPackTypes.FixArray)
+		*result = AppendArrayHeader(*result, v.Len())
 
 		// add value
 		for i := 0; i < v.Len(); i++ {
-			elem := v.Index(i).Interface()
-			m.handleValue(result, elem)
+			if err := m.encodeElem(result, v.Index(i), opts); err != nil {
+				return err
+			}
 		}
 
 	case reflect.Map:
 		if v.Type().Key().Kind() == reflect.String {
+			if !v.IsNil() {
+				if err := opts.enter(v); err != nil {
+					return err
+				}
+				defer opts.leave(v)
+			}
+
 			keys := v.MapKeys()
+			if opts.SortMapKeys {
+				sort.Slice(keys, func(i, j int) bool {
+					return keys[i].String() < keys[j].String()
+				})
+			}
 
 			// add type prefix
-			*result = append(*result, byte(len(keys))+MsgPackTypes.FixMap)
+			*result = AppendMapHeader(*result, len(keys))
 
 			// add value
 			for _, key := range keys {
-				keyAsBytes := []byte(key.String())
-
-				// add type prefix for key
-				*result = append(*result, byte(len(keyAsBytes))+MsgPackTypes.FixStr)
-
-				// add content of key
-				*result = append(*result, keyAsBytes...)
+				if err := m.encodeMapKey(result, key.String(), opts); err != nil {
+					return err
+				}
 
 				// add value
-				elem := v.MapIndex(key).Interface()
-				m.handleValue(result, elem)
+				if err := m.encodeElem(result, v.MapIndex(key), opts); err != nil {
+					return err
+				}
 			}
 		} else {
-			fmt.Println("Non-string keys in map are not supported.")
+			return &UnsupportedTypeError{Type: v.Type()}
 		}
+
+	case reflect.Struct:
+		return m.encodeStruct(result, v, opts)
+
 	default:
-		fmt.Println("unhandle case.", v.Kind(), v)
+		return &UnsupportedTypeError{Type: v.Type()}
 	}
 
 	return nil
 }
 
-func (m *Msgpack) encodeMsgPackTypeNumberFamily(result *[]byte, num json.Number) (*[]byte, error) {
-
-	var buf bytes.Buffer
+// encodePointer writes the value the non-nil pointer v points to.
+func (m *Msgpack) encodePointer(result *[]byte, v reflect.Value, opts *encodeState) error {
+	if err := opts.enter(v); err != nil {
+		return err
+	}
+	defer opts.leave(v)
 
-	if ui, err := strconv.ParseUint(num.String(), 10, 64); err == nil {
-		switch {
-		case ui <= 127:
-			*result = append(*result, byte(ui))
+	return m.handleValue(result, v.Elem().Interface(), opts)
+}
 
-		case ui <= math.MaxUint8:
-			*result = append(*result, byte(MsgPackTypes.Uint8))
-			*result = append(*result, byte(uint8(ui)))
+// encodeElem writes an element of a slice, array or map,
+// elements of interface type may hold values of registered types.
+func (m *Msgpack) encodeElem(result *[]byte, elem reflect.Value, opts *encodeState) error {
+	if elem.Kind() == reflect.Interface {
+		return m.encodeInterface(result, elem.Interface(), opts)
+	}
+	return m.handleValue(result, elem.Interface(), opts)
+}
 
-		case ui <= math.MaxUint16:
-			*result = append(*result, byte(MsgPackTypes.Uint16))
-			binary.Write(&buf, binary.BigEndian, uint16(ui))
-			*result = append(*result, buf.Bytes()...)
+// encodeBytes writes b as a bin value, or as a raw value in LegacyRaw mode.
+func (m *Msgpack) encodeBytes(result *[]byte, b []byte, opts *encodeState) {
+	if opts.LegacyRaw {
+		*result = append(appendRawHeader(*result, len(b)), b...)
+		return
+	}
+	*result = AppendBytes(*result, b)
+}
 
-		case ui <= math.MaxUint32:
-			*result = append(*result, byte(MsgPackTypes.Uint32))
-			binary.Write(&buf, binary.BigEndian, uint32(ui))
-			*result = append(*result, buf.Bytes()...)
+// encodeFloat64 writes f as a float64, or as a float32 when opts.NarrowFloats is set and f fits one exactly.
+func encodeFloat64(result *[]byte, f float64, opts *encodeState) {
+	if opts.NarrowFloats && float64(float32(f)) == f {
+		*result = AppendFloat32(*result, float32(f))
+		return
+	}
+	*result = AppendFloat64(*result, f)
+}
 
-		default: // ===> case ui <= math.MaxUint64:
-			*result = append(*result, byte(MsgPackTypes.Uint64))
-			binary.Write(&buf, binary.BigEndian, uint64(ui))
-			*result = append(*result, buf.Bytes()...)
-		}
-	} else if i, err := num.Int64(); err == nil {
-
-		switch {
-		case i < 0 && i >= -32:
-			*result = append(*result, byte(i))
-
-		case i >= math.MinInt8 && i <= math.MaxInt8:
-			*result = append(*result, byte(MsgPackTypes.Int8))
-			*result = append(*result, byte(int8(i)))
-
-		case i >= math.MinInt16 && i <= math.MaxInt16:
-			*result = append(*result, byte(MsgPackTypes.Int16))
-			binary.Write(&buf, binary.BigEndian, int16(i))
-			*result = append(*result, buf.Bytes()...)
-
-		case i >= math.MinInt32 && i <= math.MaxInt32:
-			*result = append(*result, byte(MsgPackTypes.Int32))
-			binary.Write(&buf, binary.BigEndian, int32(i))
-			*result = append(*result, buf.Bytes()...)
-
-		default: // ===> case i >= math.MinInt64 && i <= math.MaxInt64:
-			*result = append(*result, byte(MsgPackTypes.Int64))
-			binary.Write(&buf, binary.BigEndian, int64(i))
-			*result = append(*result, buf.Bytes()...)
-		}
-	} else if f, err := num.Float64(); err == nil {
-		switch {
-		// case f >= math.SmallestNonzeroFloat32 && f <= math.MaxFloat32:
-		// 	*result = append(*result, byte(MsgPackTypes.Float32))
-		// 	binary.Write(&buf, binary.BigEndian, float32(f))
-		// 	*result = append(*result, buf.Bytes()...)
-
-		default: // ===> case f >= math.SmallestNonzeroFloat64 && f <= math.MaxFloat64:
-			*result = append(*result, byte(MsgPackTypes.Float64))
-			binary.Write(&buf, binary.BigEndian, float64(f))
-			*result = append(*result, buf.Bytes()...)
-		}
+// parseUint parses a non-negative integer, strings that cannot be one are rejected
+// before strconv is called because a failed parse allocates its error.
+func parseUint(s string) (uint64, bool) {
+	if strings.ContainsAny(s, "-.eE") {
+		return 0, false
 	}
+	ui, err := strconv.ParseUint(s, 10, 64)
+	return ui, err == nil
+}
 
-	return result, nil
+// parseInt parses an integer, see parseUint.
+func parseInt(s string) (int64, bool) {
+	if strings.ContainsAny(s, ".eE") {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil
 }
@@ -150,3 +150,233 @@ func TestMarshal(t *testing.T) {
 		})
 	}
 }
+
+func TestAppendMarshal(t *testing.T) {
+	mp := NewMsgpack()
+
+	prefix := []byte{0x01, 0x02}
+
+	m1, err := mp.AppendMarshal(prefix, []interface{}{"John", json.Number("-18"), true})
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x02, 0x93, 0xa4, 0x4a, 0x6f, 0x68, 0x6e, 0xee, 0xc3}, m1)
+}
+
+func TestAppendMarshalAllocs(t *testing.T) {
+	mp := NewMsgpack()
+	data := benchmarkJSONObj()
+	buf := make([]byte, 0, 1024)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := mp.AppendMarshal(buf[:0], data); err != nil {
+			t.Fatal(err)
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func TestMarshalPointers(t *testing.T) {
+	mp := NewMsgpack()
+
+	n := 18
+	name := "John"
+	pn := &n
+
+	testCases := []struct {
+		desc     string
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - pointer to int",
+			input:    &n,
+			expected: []byte{0x12},
+		},
+		{
+			desc:     "Test Case - pointer to pointer",
+			input:    &pn,
+			expected: []byte{0x12},
+		},
+		{
+			desc:     "Test Case - nil pointer",
+			input:    (*int)(nil),
+			expected: []byte{0xc0},
+		},
+		{
+			desc: "Test Case - pointer fields",
+			input: &struct {
+				Name *string `msgpack:"name"`
+				Age  *int    `msgpack:"age"`
+			}{Name: &name},
+			expected: []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa4, 0x4a, 0x6f, 0x68, 0x6e, 0xa3, 0x61, 0x67, 0x65, 0xc0},
+		},
+		{
+			desc:     "Test Case - pointers in a map",
+			input:    map[string]interface{}{"age": &n},
+			expected: []byte{0x81, 0xa3, 0x61, 0x67, 0x65, 0x12},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestMarshalPointerCycle(t *testing.T) {
+	type node struct {
+		Value int
+		Next  *node
+	}
+
+	mp := NewMsgpack()
+
+	// a long chain without a cycle
+	var chain *node
+	for i := 0; i < 2*startDetectingCyclesAfter; i++ {
+		chain = &node{Value: i, Next: chain}
+	}
+	_, err := mp.AppendMarshal(nil, chain)
+	require.NoError(t, err)
+
+	a := &node{Value: 1}
+	b := &node{Value: 2, Next: a}
+	a.Next = b
+	_, err = mp.AppendMarshal(nil, a)
+	require.ErrorContains(t, err, "cycle")
+
+	// the same pointer twice is not a cycle
+	shared := &node{Value: 3}
+	_, err = mp.AppendMarshal(nil, []*node{shared, shared})
+	require.NoError(t, err)
+
+	// cycles through slices and maps
+	s := []interface{}{nil}
+	s[0] = s
+	_, err = mp.AppendMarshal(nil, s)
+	require.ErrorContains(t, err, "cycle")
+
+	m := map[string]interface{}{}
+	m["self"] = m
+	_, err = mp.AppendMarshal(nil, m)
+	require.ErrorContains(t, err, "cycle")
+
+	type list []interface{}
+	l := list{1, nil}
+	l[1] = l
+	_, err = mp.AppendMarshal(nil, l)
+	require.ErrorContains(t, err, "cycle")
+
+	type table map[string]interface{}
+	tb := table{}
+	tb["self"] = tb
+	_, err = mp.AppendMarshal(nil, tb)
+	require.ErrorContains(t, err, "cycle")
+
+	type withInline struct {
+		Rest map[string]interface{} `msgpack:",inline"`
+	}
+	rest := map[string]interface{}{}
+	rest["self"] = withInline{Rest: rest}
+	_, err = mp.AppendMarshal(nil, withInline{Rest: rest})
+	require.ErrorContains(t, err, "cycle")
+
+	// a long chain of slices without a cycle
+	var nested interface{}
+	for i := 0; i < 2*startDetectingCyclesAfter; i++ {
+		nested = []interface{}{nested}
+	}
+	_, err = mp.AppendMarshal(nil, nested)
+	require.NoError(t, err)
+}
+
+func benchmarkJSONObj() map[string]interface{} {
+	return map[string]interface{}{
+		"name":     "John Doe",
+		"email":    "john@doe.com",
+		"age":      json.Number("35"),
+		"balance":  json.Number("-1234567"),
+		"score":    99.5,
+		"rate":     json.Number("0.25"),
+		"active":   true,
+		"verified": false,
+		"manager":  nil,
+		"tags":     []interface{}{"a", "b", json.Number("300")},
+		"address": map[string]interface{}{
+			"city": "Taipei",
+			"zip":  json.Number("100"),
+		},
+	}
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	mp := NewMsgpack()
+	data := benchmarkJSONObj()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mp.Marshal(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAppendMarshal(b *testing.B) {
+	mp := NewMsgpack()
+	data := benchmarkJSONObj()
+	buf := make([]byte, 0, 1024)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var err error
+		if buf, err = mp.AppendMarshal(buf[:0], data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMarshalUnsupportedType(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		input    interface{}
+		expected string
+	}{
+		{
+			desc:     "Test Case - map with int keys",
+			input:    map[string]interface{}{"a": map[int]int{1: 2}, "b": 1},
+			expected: "map[int]int",
+		},
+		{
+			desc:     "Test Case - channel",
+			input:    []interface{}{make(chan int)},
+			expected: "chan int",
+		},
+		{
+			desc:     "Test Case - function",
+			input:    func() {},
+			expected: "func()",
+		},
+		{
+			desc: "Test Case - complex struct field",
+			input: struct {
+				C complex128 `msgpack:"c"`
+			}{},
+			expected: "complex128",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			prefix := []byte{0x01}
+			result, err := mp.AppendMarshal(prefix, tc.input)
+			var typeErr *UnsupportedTypeError
+			require.ErrorAs(t, err, &typeErr)
+			require.Equal(t, tc.expected, typeErr.Type.String())
+			require.Equal(t, prefix, result)
+		})
+	}
+}
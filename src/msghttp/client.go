@@ -0,0 +1,220 @@
+package msghttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	msgpack "msgpack/src"
+)
+
+// acceptMsgpack asks for MessagePack responses, JSON ones are accepted as well.
+const acceptMsgpack = ContentTypeMsgpack + ", " + ContentTypeJSON + ";q=0.9"
+
+// StatusError is a response with a status code outside of 2xx.
+type StatusError struct {
+	StatusCode  int
+	Status      string
+	ContentType string
+	Body        []byte
+
+	opts *Options
+}
+
+func (e *StatusError) Error() string {
+	if value, err := e.bodyValue(); err == nil && value != nil {
+		return fmt.Sprintf("unexpected status %s: %v", e.Status, value)
+	}
+	return "unexpected status " + e.Status
+}
+
+// Decode decodes a MessagePack or JSON body into the value pointed to by v, a typed error for example.
+func (e *StatusError) Decode(v interface{}) error {
+	data, err := e.msgpackBody()
+	if err != nil {
+		return err
+	}
+	return e.msgpack().UnmarshalTo(data, v)
+}
+
+// msgpack returns the Msgpack of the request, also for a StatusError created elsewhere.
+func (e *StatusError) msgpack() *msgpack.Msgpack {
+	if e.opts == nil {
+		return defaultOptions.Msgpack
+	}
+	return e.opts.Msgpack
+}
+
+// msgpackBody returns the body in MessagePack form.
+func (e *StatusError) msgpackBody() ([]byte, error) {
+	switch {
+	case isMsgpack(e.ContentType):
+		return e.Body, nil
+	case e.ContentType == ContentTypeJSON:
+		return jsonToMsgpack(e.msgpack(), e.Body)
+	}
+	return nil, fmt.Errorf("cannot decode body of content type %q", e.ContentType)
+}
+
+// bodyValue returns the body in its generic Go form.
+func (e *StatusError) bodyValue() (interface{}, error) {
+	data, err := e.msgpackBody()
+	if err != nil {
+		return nil, err
+	}
+	value, n, err := e.msgpack().DecodeNext(data)
+	if err == nil && n != len(data) {
+		err = fmt.Errorf("trailing data")
+	}
+	return value, err
+}
+
+// DoMsgpack sends req with client, http.DefaultClient when nil, using the default Options.
+//
+// Unless in is nil it is encoded as the MessagePack body of the request. The response is asked for
+// as MessagePack and decoded into the value pointed to by out, unless out is nil or the response has no body,
+// JSON responses are decoded with the msgpack struct tags as well.
+// A response with a status code outside of 2xx is returned as a *StatusError.
+// The response body is read whole without a size limit, DoMsgpackWithOptions sets one.
+func DoMsgpack(client *http.Client, req *http.Request, in, out interface{}) error {
+	return DoMsgpackWithOptions(client, req, in, out, Options{})
+}
+
+// DoMsgpackWithOptions is like DoMsgpack but configured by opts,
+// opts.MaxResponseSize limits the size of the response body.
+func DoMsgpackWithOptions(client *http.Client, req *http.Request, in, out interface{}, opts Options) error {
+	opts = opts.withDefaults()
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req = req.Clone(req.Context())
+	if in != nil {
+		body, err := opts.Msgpack.AppendMarshal(nil, in)
+		if err != nil {
+			return err
+		}
+		setBody(req, body, ContentTypeMsgpack)
+	}
+	if req.Header.Get("Accept") == "" {
+		req.Header.Set("Accept", acceptMsgpack)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := readResponseBody(resp.Body, opts.MaxResponseSize)
+	if err != nil {
+		return err
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{
+			StatusCode:  resp.StatusCode,
+			Status:      resp.Status,
+			ContentType: contentType,
+			Body:        body,
+			opts:        &opts,
+		}
+	}
+
+	if out == nil || len(body) == 0 {
+		return nil
+	}
+
+	switch {
+	case isMsgpack(contentType):
+	case contentType == ContentTypeJSON:
+		if body, err = jsonToMsgpack(opts.Msgpack, body); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unexpected content type %q", contentType)
+	}
+	return opts.Msgpack.UnmarshalTo(body, out)
+}
+
+// Transport is an http.RoundTripper that moves JSON APIs to MessagePack on the wire, so that
+// clients written for JSON keep working unchanged: JSON request bodies are sent as MessagePack,
+// MessagePack responses are asked for and handed back as JSON.
+type Transport struct {
+	// Base sends the requests, http.DefaultTransport when nil.
+	Base http.RoundTripper
+	// Options configures the transcoding, Options.MaxResponseSize limits the size of the MessagePack
+	// response bodies it transcodes. Other response bodies are passed through as they are.
+	Options Options
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	opts := t.Options.withDefaults()
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// RoundTrip must not modify req
+	req = req.Clone(req.Context())
+
+	// encode request body
+	contentType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if req.Body != nil && req.Body != http.NoBody && contentType == ContentTypeJSON {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if body, err = jsonToMsgpack(opts.Msgpack, body); err != nil {
+			return nil, fmt.Errorf("encoding request body: %w", err)
+		}
+		setBody(req, body, ContentTypeMsgpack)
+	}
+
+	if accept := req.Header.Get("Accept"); accept == "" || accept == ContentTypeJSON {
+		req.Header.Set("Accept", acceptMsgpack)
+	}
+
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// decode response body
+	contentType, _, _ = mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !isMsgpack(contentType) {
+		return resp, nil
+	}
+
+	body, err := readResponseBody(resp.Body, opts.MaxResponseSize)
+	resp.Body.Close()
+	if err == nil {
+		body, err = msgpackToJSON(opts.Msgpack, body)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding response body: %w", err)
+	}
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header = resp.Header.Clone()
+	resp.Header.Set("Content-Type", ContentTypeJSON)
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return resp, nil
+}
+
+// setBody replaces the body of req with body of contentType.
+func setBody(req *http.Request, body []byte, contentType string) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	req.Header.Set("Content-Type", contentType)
+}
@@ -0,0 +1,188 @@
+package msghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type apiError struct {
+	Code    int    `msgpack:"code"`
+	Message string `msgpack:"message"`
+}
+
+func newItemServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items", func(w http.ResponseWriter, r *http.Request) {
+		var in item
+		if err := ReadMsgpack(r, &in); err != nil {
+			WriteMsgpack(w, err.(*Error).Status, apiError{Code: 1, Message: err.Error()})
+			return
+		}
+		if in.Count < 0 {
+			WriteMsgpack(w, http.StatusUnprocessableEntity, apiError{Code: 2, Message: "negative count"})
+			return
+		}
+		in.Count *= 2
+		WriteMsgpack(w, http.StatusOK, in)
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	})
+
+	server := httptest.NewServer(Middleware(mux))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDoMsgpack(t *testing.T) {
+	server := newItemServer(t)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/items", nil)
+	require.NoError(t, err)
+
+	var out item
+	require.NoError(t, DoMsgpack(server.Client(), req, item{Name: "a", Count: 2}, &out))
+	require.Equal(t, item{Name: "a", Count: 4}, out)
+
+	// the request can be sent again
+	out = item{}
+	require.NoError(t, DoMsgpack(nil, req, item{Name: "b", Count: 3}, &out))
+	require.Equal(t, item{Name: "b", Count: 6}, out)
+
+	// no body to decode
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/empty", nil)
+	require.NoError(t, err)
+	require.NoError(t, DoMsgpack(server.Client(), req, nil, &out))
+}
+
+func TestDoMsgpackJSONResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeJSON)
+		io.WriteString(w, `{"name":"j","count":7}`)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	var out item
+	require.NoError(t, DoMsgpack(server.Client(), req, nil, &out))
+	require.Equal(t, item{Name: "j", Count: 7}, out)
+}
+
+func TestDoMsgpackErrors(t *testing.T) {
+	server := newItemServer(t)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/items", nil)
+	require.NoError(t, err)
+
+	err = DoMsgpack(server.Client(), req, item{Count: -1}, nil)
+	var statusErr *StatusError
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	require.Equal(t, http.StatusUnprocessableEntity, statusErr.StatusCode)
+	require.Equal(t, ContentTypeMsgpack, statusErr.ContentType)
+	require.Equal(t, "unexpected status 422 Unprocessable Entity: map[code:2 message:negative count]", statusErr.Error())
+
+	var apiErr apiError
+	require.NoError(t, statusErr.Decode(&apiErr))
+	require.Equal(t, apiError{Code: 2, Message: "negative count"}, apiErr)
+
+	// a body that is no item
+	err = DoMsgpack(server.Client(), req, []int{1}, nil)
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	require.Equal(t, http.StatusBadRequest, statusErr.StatusCode)
+	require.NoError(t, statusErr.Decode(&apiErr))
+	require.Equal(t, 1, apiErr.Code)
+
+	// plain text errors cannot be decoded
+	req, err = http.NewRequest(http.MethodGet, server.URL+"/text", nil)
+	require.NoError(t, err)
+	err = DoMsgpack(server.Client(), req, nil, nil)
+	require.True(t, errors.As(err, &statusErr), "got %v", err)
+	require.Equal(t, "unexpected status 410 Gone", statusErr.Error())
+	require.Error(t, statusErr.Decode(&apiErr))
+}
+
+func TestResponseTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeMsgpack)
+		w.Write(append([]byte{0xc5, 0x08, 0x00}, make([]byte, 2048)...))
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+
+	// no limit by default
+	var out []byte
+	require.NoError(t, DoMsgpack(server.Client(), req, nil, &out))
+	require.Len(t, out, 2048)
+
+	err = DoMsgpackWithOptions(server.Client(), req, nil, &out, Options{MaxResponseSize: 1024})
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport, Options: Options{MaxResponseSize: 1024}}}
+	_, err = client.Get(server.URL)
+	require.ErrorIs(t, err, ErrResponseTooLarge)
+}
+
+func TestTransport(t *testing.T) {
+	var gotContentType, gotAccept string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotContentType = r.Header.Get("Content-Type")
+		gotAccept = r.Header.Get("Accept")
+		gotBody, _ = io.ReadAll(r.Body)
+
+		w.Header().Set("Content-Type", ContentTypeMsgpack)
+		w.Write([]byte{0x81, 0xa2, 0x6f, 0x6b, 0xc3})
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+
+	// a JSON client
+	resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"a": 1}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, ContentTypeMsgpack, gotContentType)
+	require.Equal(t, acceptMsgpack, gotAccept)
+	require.Equal(t, []byte{0x81, 0xa1, 0x61, 0x01}, gotBody)
+
+	require.Equal(t, ContentTypeJSON, resp.Header.Get("Content-Type"))
+	var v map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&v))
+	require.Equal(t, map[string]interface{}{"ok": true}, v)
+}
+
+func TestTransportWithMiddleware(t *testing.T) {
+	server := httptest.NewServer(Middleware(http.HandlerFunc(echoJSON)))
+	defer server.Close()
+
+	client := &http.Client{Transport: &Transport{Base: server.Client().Transport}}
+
+	resp, err := client.Post(server.URL, "application/json", bytes.NewReader([]byte(`{"a":1}`)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.JSONEq(t, `{"a":1,"echo":true}`, string(body))
+
+	// invalid JSON is not sent
+	_, err = client.Post(server.URL, "application/json", strings.NewReader(`{`))
+	require.Error(t, err)
+}
@@ -0,0 +1,145 @@
+package msghttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+// Middleware serves next to MessagePack and JSON clients alike with the default Options.
+//
+// Request bodies sent as MessagePack are transcoded to JSON before they reach next, bodies that do not
+// decode are answered with 400 Bad Request and bodies larger than the maximum body size with
+// 413 Request Entity Too Large. Other bodies reach next as they are, limited to the maximum body size
+// by http.MaxBytesReader: reading past it fails with an *http.MaxBytesError, which ReadMsgpack
+// reports as 413 and other handlers answer as they see fit.
+// JSON responses of next are transcoded to MessagePack when the Accept header of the request
+// ranks MessagePack above JSON.
+func Middleware(next http.Handler) http.Handler {
+	return MiddlewareWithOptions(next, Options{})
+}
+
+// MiddlewareWithOptions is like Middleware but configured by opts.
+func MiddlewareWithOptions(next http.Handler, opts Options) http.Handler {
+	opts = opts.withDefaults()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), optionsKey{}, &opts))
+		w.Header().Add("Vary", "Accept")
+
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, opts.MaxBodySize)
+			if err := transcodeRequest(r, &opts); err != nil {
+				http.Error(w, err.Error(), err.Status)
+				return
+			}
+		}
+
+		rw := &responseWriter{ResponseWriter: w, opts: &opts, msgpack: prefersMsgpack(r.Header.Get("Accept"))}
+		next.ServeHTTP(rw, r)
+		rw.finish()
+	})
+}
+
+// transcodeRequest replaces a MessagePack body of r with its JSON form.
+func transcodeRequest(r *http.Request, opts *Options) *Error {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !isMsgpack(contentType) {
+		return nil
+	}
+
+	body, err := readBody(r.Body, opts.MaxBodySize)
+	if err != nil {
+		return err.(*Error)
+	}
+	if body, err = msgpackToJSON(opts.Msgpack, body); err != nil {
+		return &Error{Status: http.StatusBadRequest, Err: err}
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r.Header = r.Header.Clone()
+	r.Header.Set("Content-Type", ContentTypeJSON)
+	r.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+// responseWriter holds back JSON responses for clients preferring MessagePack to transcode them once complete.
+type responseWriter struct {
+	http.ResponseWriter
+	opts    *Options
+	msgpack bool // the client prefers MessagePack
+
+	wroteHeader bool
+	buffering   bool
+	status      int
+	buf         bytes.Buffer
+}
+
+func (rw *responseWriter) WriteHeader(status int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
+	contentType, _, _ := mime.ParseMediaType(rw.Header().Get("Content-Type"))
+	if rw.msgpack && contentType == ContentTypeJSON && bodyAllowed(status) {
+		rw.buffering = true
+		rw.status = status
+		return
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	if !rw.wroteHeader {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.buffering {
+		return rw.buf.Write(p)
+	}
+	return rw.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// finish writes a held back response, as MessagePack unless it is not valid JSON.
+func (rw *responseWriter) finish() {
+	if !rw.buffering {
+		return
+	}
+
+	body := rw.buf.Bytes()
+	if converted, err := jsonToMsgpack(rw.opts.Msgpack, body); err == nil {
+		body = converted
+		rw.Header().Set("Content-Type", ContentTypeMsgpack)
+	}
+	rw.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	rw.ResponseWriter.WriteHeader(rw.status)
+	rw.ResponseWriter.Write(body)
+}
+
+// findResponseWriter returns the responseWriter of Middleware wrapped by w, or nil.
+func findResponseWriter(w http.ResponseWriter) *responseWriter {
+	for {
+		switch v := w.(type) {
+		case *responseWriter:
+			return v
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// bodyAllowed reports whether a response of status has a body.
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
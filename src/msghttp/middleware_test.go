@@ -0,0 +1,190 @@
+package msghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "msgpack/src"
+)
+
+// echoJSON is a plain JSON handler that knows nothing about MessagePack.
+func echoJSON(w http.ResponseWriter, r *http.Request) {
+	var v map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	v["echo"] = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func TestMiddleware(t *testing.T) {
+	server := httptest.NewServer(MiddlewareWithOptions(http.HandlerFunc(echoJSON), Options{MaxBodySize: 64}))
+	defer server.Close()
+
+	testCases := []struct {
+		desc        string
+		contentType string
+		accept      string
+		body        []byte
+		status      int
+		respType    string
+		respBody    []byte
+		respValue   map[string]interface{}
+	}{
+		{
+			desc:        "Test Case - JSON client",
+			contentType: ContentTypeJSON,
+			body:        []byte(`{"a":1}`),
+			status:      http.StatusOK,
+			respType:    ContentTypeJSON,
+			respBody:    []byte("{\"a\":1,\"echo\":true}\n"),
+		},
+		{
+			desc:        "Test Case - msgpack client",
+			contentType: ContentTypeMsgpack,
+			accept:      ContentTypeMsgpack,
+			body:        []byte{0x81, 0xa1, 0x61, 0x01},
+			status:      http.StatusOK,
+			respType:    ContentTypeMsgpack,
+			respValue:   map[string]interface{}{"a": 1, "echo": true},
+		},
+		{
+			desc:        "Test Case - msgpack request with JSON response",
+			contentType: ContentTypeMsgpack,
+			body:        []byte{0x81, 0xa1, 0x61, 0x01},
+			status:      http.StatusOK,
+			respType:    ContentTypeJSON,
+			respBody:    []byte("{\"a\":1,\"echo\":true}\n"),
+		},
+		{
+			desc:        "Test Case - invalid msgpack body",
+			contentType: ContentTypeMsgpack,
+			accept:      ContentTypeMsgpack,
+			body:        []byte{0x81, 0xa1},
+			status:      http.StatusBadRequest,
+		},
+		{
+			desc:        "Test Case - msgpack body too large",
+			contentType: ContentTypeMsgpack,
+			body:        append([]byte{0xc4, 0x64}, make([]byte, 100)...),
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			// the JSON handler answers the *http.MaxBytesError itself
+			desc:        "Test Case - JSON body too large",
+			contentType: ContentTypeJSON,
+			body:        []byte(`{"a":"` + string(bytes.Repeat([]byte{'x'}, 100)) + `"}`),
+			status:      http.StatusBadRequest,
+		},
+		{
+			desc:        "Test Case - JSON error response stays as it is",
+			contentType: ContentTypeJSON,
+			accept:      ContentTypeMsgpack,
+			body:        []byte(`[`),
+			status:      http.StatusBadRequest,
+			respType:    "text/plain; charset=utf-8",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, server.URL, bytes.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.status, resp.StatusCode, string(body))
+			require.Equal(t, "Accept", resp.Header.Get("Vary"))
+			if tc.respType != "" {
+				require.Equal(t, tc.respType, resp.Header.Get("Content-Type"))
+			}
+			if tc.respBody != nil {
+				require.Equal(t, tc.respBody, body)
+			}
+			if tc.respValue != nil {
+				// the order of the keys is not kept
+				value, err := msgpack.NewMsgpack().Unmarshal(body)
+				require.NoError(t, err)
+				require.Equal(t, tc.respValue, value)
+			}
+		})
+	}
+}
+
+func TestMiddlewareHelpers(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in item
+		if err := ReadMsgpack(r, &in); err != nil {
+			http.Error(w, err.Error(), err.(*Error).Status)
+			return
+		}
+		in.Count++
+		WriteMsgpack(w, http.StatusOK, in)
+	}))
+
+	testCases := []struct {
+		desc        string
+		contentType string
+		accept      string
+		body        []byte
+		respType    string
+		respBody    []byte
+	}{
+		{
+			desc:        "Test Case - msgpack in, msgpack out",
+			contentType: ContentTypeMsgpack,
+			accept:      ContentTypeMsgpack,
+			body:        []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x02},
+			respType:    ContentTypeMsgpack,
+			respBody:    []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x03},
+		},
+		{
+			desc:        "Test Case - msgpack in, JSON out",
+			contentType: ContentTypeMsgpack,
+			body:        []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x02},
+			respType:    ContentTypeJSON,
+			respBody:    []byte(`{"count":3,"name":"a"}`),
+		},
+		{
+			desc:        "Test Case - JSON in, msgpack out",
+			contentType: ContentTypeJSON,
+			accept:      ContentTypeMsgpack,
+			body:        []byte(`{"name":"a","count":2}`),
+			respType:    ContentTypeMsgpack,
+			respBody:    []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x03},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tc.body))
+			r.Header.Set("Content-Type", tc.contentType)
+			if tc.accept != "" {
+				r.Header.Set("Accept", tc.accept)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			require.Equal(t, tc.respType, w.Header().Get("Content-Type"))
+			require.Equal(t, tc.respBody, w.Body.Bytes())
+		})
+	}
+}
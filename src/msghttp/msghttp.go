@@ -0,0 +1,257 @@
+// Package msghttp serves HTTP APIs as MessagePack or JSON, as negotiated by the Accept header.
+//
+// Middleware lets JSON handlers speak MessagePack: request bodies sent as application/msgpack reach them as JSON,
+// and their JSON responses are sent as MessagePack to clients that prefer it.
+// Handlers written for both use ReadMsgpack and WriteMsgpack, which read and write either format
+// with the msgpack struct tags.
+//
+// On the client side DoMsgpack sends typed requests and decodes typed responses,
+// and Transport moves JSON clients to MessagePack on the wire without changing them.
+package msghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	msgpack "msgpack/src"
+)
+
+// Content types of the two formats.
+const (
+	ContentTypeMsgpack = "application/msgpack"
+	ContentTypeJSON    = "application/json"
+)
+
+// DefaultMaxBodySize is the request body size allowed when Options.MaxBodySize is zero.
+const DefaultMaxBodySize = 1 << 20
+
+// Options configures Middleware, Transport and DoMsgpackWithOptions.
+type Options struct {
+	// Msgpack encodes and decodes the bodies, a Msgpack with the default options when nil.
+	Msgpack *msgpack.Msgpack
+	// MaxBodySize is the maximum size in bytes of the request bodies read by Middleware and ReadMsgpack.
+	// DefaultMaxBodySize applies when it is zero.
+	MaxBodySize int64
+	// MaxResponseSize is the maximum size in bytes of the response bodies read by Transport and
+	// DoMsgpackWithOptions, larger ones fail with ErrResponseTooLarge. They are not limited when it is zero.
+	MaxResponseSize int64
+}
+
+// ErrResponseTooLarge is returned by Transport and DoMsgpackWithOptions for a response body
+// exceeding Options.MaxResponseSize.
+var ErrResponseTooLarge = errors.New("msghttp: response body too large")
+
+var defaultOptions = Options{Msgpack: msgpack.NewMsgpack(), MaxBodySize: DefaultMaxBodySize}
+
+func (o Options) withDefaults() Options {
+	if o.Msgpack == nil {
+		o.Msgpack = defaultOptions.Msgpack
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = DefaultMaxBodySize
+	}
+	return o
+}
+
+// optionsKey is the context key of the Options of Middleware.
+type optionsKey struct{}
+
+// optionsFrom returns the Options of the Middleware serving ctx, or the defaults.
+func optionsFrom(ctx context.Context) *Options {
+	if opts, ok := ctx.Value(optionsKey{}).(*Options); ok {
+		return opts
+	}
+	return &defaultOptions
+}
+
+// Error is a request body that cannot be read, Status is the matching HTTP status code.
+type Error struct {
+	Status int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ReadMsgpack decodes the application/msgpack or application/json body of r, a body without
+// Content-Type counts as MessagePack, into the value pointed to by v.
+// It returns an *Error with status 400 for a body that does not decode, 413 for a body exceeding
+// the maximum body size and 415 for other content types.
+func ReadMsgpack(r *http.Request, v interface{}) error {
+	opts := optionsFrom(r.Context())
+
+	var contentType string
+	if header := r.Header.Get("Content-Type"); header != "" {
+		contentType, _, _ = mime.ParseMediaType(header)
+	}
+	if contentType != "" && !isMsgpack(contentType) && contentType != ContentTypeJSON {
+		return &Error{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("unsupported content type %q", contentType)}
+	}
+
+	body, err := readBody(r.Body, opts.MaxBodySize)
+	if err != nil {
+		return err
+	}
+
+	// JSON goes through the MessagePack form to apply the msgpack struct tags
+	if contentType == ContentTypeJSON {
+		if body, err = jsonToMsgpack(opts.Msgpack, body); err != nil {
+			return &Error{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+
+	if err := opts.Msgpack.UnmarshalTo(body, v); err != nil {
+		return &Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return nil
+}
+
+// WriteMsgpack writes v with status. Behind Middleware it is written as MessagePack or JSON,
+// whichever the client prefers, otherwise always as MessagePack.
+// Nothing is written when v cannot be encoded.
+func WriteMsgpack(w http.ResponseWriter, status int, v interface{}) error {
+	opts, asJSON := &defaultOptions, false
+	if rw := findResponseWriter(w); rw != nil {
+		opts, asJSON = rw.opts, !rw.msgpack
+	}
+
+	body, err := opts.Msgpack.AppendMarshal(nil, v)
+	if err != nil {
+		return err
+	}
+	contentType := ContentTypeMsgpack
+	if asJSON {
+		if body, err = msgpackToJSON(opts.Msgpack, body); err != nil {
+			return err
+		}
+		contentType = ContentTypeJSON
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// readBody reads body up to maxSize bytes, its errors are *Error values.
+func readBody(body io.Reader, maxSize int64) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) || int64(len(data)) > maxSize {
+		return nil, &Error{Status: http.StatusRequestEntityTooLarge, Err: fmt.Errorf("body exceeds %d bytes", maxSize)}
+	}
+	if err != nil {
+		return nil, &Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return data, nil
+}
+
+// readResponseBody reads body up to maxSize bytes, or all of it when maxSize is zero.
+func readResponseBody(body io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize <= 0 {
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxSize+1))
+	if int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", ErrResponseTooLarge, maxSize)
+	}
+	return data, err
+}
+
+// isMsgpack reports whether contentType, without parameters, is a MessagePack media type.
+func isMsgpack(contentType string) bool {
+	switch contentType {
+	case ContentTypeMsgpack, "application/x-msgpack", "application/vnd.msgpack":
+		return true
+	}
+	return false
+}
+
+// prefersMsgpack reports whether the Accept header accept ranks MessagePack above JSON.
+// Specific media types take precedence over wildcards, JSON wins ties.
+func prefersMsgpack(accept string) bool {
+	msgpackQ, jsonQ, wildcardQ := -1.0, -1.0, -1.0
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if s, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(s, 64); err != nil {
+				continue
+			}
+		}
+
+		switch {
+		case isMsgpack(mediaType):
+			msgpackQ = maxQ(msgpackQ, q)
+		case mediaType == ContentTypeJSON:
+			jsonQ = maxQ(jsonQ, q)
+		case mediaType == "*/*", mediaType == "application/*":
+			wildcardQ = maxQ(wildcardQ, q)
+		}
+	}
+
+	if msgpackQ < 0 {
+		msgpackQ = wildcardQ
+	}
+	if jsonQ < 0 {
+		jsonQ = wildcardQ
+	}
+	return msgpackQ > 0 && msgpackQ > jsonQ
+}
+
+func maxQ(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// jsonToMsgpack converts a JSON document to MessagePack, numbers keep their precision.
+func jsonToMsgpack(m *msgpack.Msgpack, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return m.AppendMarshal(nil, v)
+}
+
+// msgpackToJSON converts a MessagePack value to JSON.
+func msgpackToJSON(m *msgpack.Msgpack, data []byte) ([]byte, error) {
+	v, n, err := m.DecodeNext(data)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(data) {
+		return nil, fmt.Errorf("%w: %d bytes at offset %d", msgpack.ErrTrailingData, len(data)-n, n)
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,129 @@
+package msghttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	Name  string `msgpack:"name"`
+	Count int    `msgpack:"count"`
+}
+
+func TestReadMsgpack(t *testing.T) {
+	testCases := []struct {
+		desc        string
+		contentType string
+		body        []byte
+		expected    item
+		status      int
+	}{
+		{
+			desc:        "Test Case - msgpack body",
+			contentType: ContentTypeMsgpack,
+			body:        []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x02},
+			expected:    item{Name: "a", Count: 2},
+		},
+		{
+			desc:     "Test Case - no content type",
+			body:     []byte{0x81, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x03},
+			expected: item{Count: 3},
+		},
+		{
+			desc:        "Test Case - JSON body with msgpack tags",
+			contentType: "application/json; charset=utf-8",
+			body:        []byte(`{"name": "b", "count": 4}`),
+			expected:    item{Name: "b", Count: 4},
+		},
+		{
+			desc:        "Test Case - invalid msgpack",
+			contentType: ContentTypeMsgpack,
+			body:        []byte{0x82, 0xa4},
+			status:      http.StatusBadRequest,
+		},
+		{
+			desc:        "Test Case - invalid JSON",
+			contentType: ContentTypeJSON,
+			body:        []byte(`{"name":`),
+			status:      http.StatusBadRequest,
+		},
+		{
+			desc:        "Test Case - too large",
+			contentType: ContentTypeMsgpack,
+			body:        append([]byte{0xc4, 0xff}, bytes.Repeat([]byte{0}, DefaultMaxBodySize)...),
+			status:      http.StatusRequestEntityTooLarge,
+		},
+		{
+			desc:        "Test Case - unsupported content type",
+			contentType: "text/plain",
+			body:        []byte("a"),
+			status:      http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+
+			var result item
+			err := ReadMsgpack(r, &result)
+			if tc.status != 0 {
+				var httpErr *Error
+				require.True(t, errors.As(err, &httpErr), "got %v", err)
+				require.Equal(t, tc.status, httpErr.Status)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestWriteMsgpack(t *testing.T) {
+	w := httptest.NewRecorder()
+	require.NoError(t, WriteMsgpack(w, http.StatusCreated, item{Name: "a", Count: 2}))
+
+	require.Equal(t, http.StatusCreated, w.Code)
+	require.Equal(t, ContentTypeMsgpack, w.Header().Get("Content-Type"))
+	require.Equal(t, []byte{0x82, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61, 0xa5, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x02}, w.Body.Bytes())
+
+	// nothing is written for values that do not encode
+	w = httptest.NewRecorder()
+	require.Error(t, WriteMsgpack(w, http.StatusOK, json.Number("x")))
+	require.Zero(t, w.Body.Len())
+	require.Empty(t, w.Header().Get("Content-Type"))
+}
+
+func TestPrefersMsgpack(t *testing.T) {
+	testCases := []struct {
+		accept   string
+		expected bool
+	}{
+		{accept: "", expected: false},
+		{accept: "application/msgpack", expected: true},
+		{accept: "application/x-msgpack", expected: true},
+		{accept: "application/json", expected: false},
+		{accept: "*/*", expected: false},
+		{accept: "application/json, application/msgpack", expected: false},
+		{accept: "application/json;q=0.5, application/msgpack", expected: true},
+		{accept: "application/msgpack;q=0.9, */*;q=0.1", expected: true},
+		{accept: "application/msgpack;q=0", expected: false},
+		{accept: "text/html, application/*;q=0.2, application/msgpack;q=0.8", expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run("Test Case - "+strings.ReplaceAll(tc.accept, "/", "_"), func(t *testing.T) {
+			require.Equal(t, tc.expected, prefersMsgpack(tc.accept))
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package msgrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	msgpack "msgpack/src"
+)
+
+// Client calls the functions of a MessagePack-RPC server. It is safe for concurrent use,
+// any number of calls can be in flight at once.
+//
+// Notifications sent by the server are ignored and its requests answered with an error.
+type Client struct {
+	conn *conn
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan response
+	err     error // why the connection ended, see fail
+}
+
+// response is the outcome of a call.
+type response struct {
+	result msgpack.RawMessage
+	err    error
+}
+
+// Dial connects to the server at address on the named network, such as "tcp" or "unix".
+func Dial(ctx context.Context, m *msgpack.Msgpack, network, address string) (*Client, error) {
+	var d net.Dialer
+	rwc, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(m, rwc), nil
+}
+
+// NewClient returns a Client that calls the server on the other end of rwc, encoding and decoding messages with m.
+func NewClient(m *msgpack.Msgpack, rwc io.ReadWriteCloser) *Client {
+	c := &Client{conn: newConn(m, rwc), pending: make(map[uint32]chan response)}
+	go c.readResponses()
+	return c
+}
+
+// Call calls method with params and decodes its result into the value pointed to by result, unless result is nil.
+// It returns an *Error when the server reports an error and ctx.Err() when ctx ends before the response arrives.
+func (c *Client) Call(ctx context.Context, method string, result interface{}, params ...interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// register call
+	ch := make(chan response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	msgid := c.nextID
+	c.nextID++
+	c.pending[msgid] = ch
+	c.mu.Unlock()
+
+	if err := c.conn.write(requestType, msgid, method, paramsArray(params)); err != nil {
+		c.forget(msgid)
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.err != nil || result == nil {
+			return resp.err
+		}
+		return c.conn.m.UnmarshalTo(resp.result, result)
+
+	case <-ctx.Done():
+		c.forget(msgid)
+		return ctx.Err()
+	}
+}
+
+// Notify sends a notification of method with params, which gets no response.
+func (c *Client) Notify(method string, params ...interface{}) error {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return c.conn.write(notificationType, method, paramsArray(params))
+}
+
+// Close closes the connection, calls in flight return ErrClosed.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.conn.rwc.Close()
+}
+
+// forget removes a call that no longer waits for its response.
+func (c *Client) forget(msgid uint32) {
+	c.mu.Lock()
+	delete(c.pending, msgid)
+	c.mu.Unlock()
+}
+
+// fail ends the calls in flight and all later ones with err, unless the connection has already ended.
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	for msgid, ch := range c.pending {
+		ch <- response{err: err}
+		delete(c.pending, msgid)
+	}
+}
+
+// readResponses hands the responses to their calls until the connection ends.
+func (c *Client) readResponses() {
+	conn := c.conn
+	for {
+		elems, err := conn.read()
+		if err == nil {
+			err = c.handle(elems)
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = ErrClosed
+			} else {
+				err = fmt.Errorf("%w: %v", ErrClosed, err)
+			}
+			c.fail(err)
+			conn.rwc.Close()
+			return
+		}
+	}
+}
+
+// handle handles a message sent by the server.
+func (c *Client) handle(elems []msgpack.RawMessage) error {
+	conn := c.conn
+
+	typ, err := conn.messageType(elems)
+	if err != nil {
+		return err
+	}
+
+	if typ == notificationType {
+		return nil
+	}
+
+	var msgid uint32
+	if err := conn.m.UnmarshalTo(elems[1], &msgid); err != nil {
+		return fmt.Errorf("invalid msgid: %w", err)
+	}
+	if typ == requestType {
+		return conn.write(responseType, msgid, "client does not serve requests", nil)
+	}
+
+	var resp response
+	var errValue interface{}
+	if err := conn.m.UnmarshalTo(elems[2], &errValue); err != nil {
+		return fmt.Errorf("invalid error: %w", err)
+	}
+	if errValue != nil {
+		resp.err = &Error{Value: errValue}
+	} else {
+		resp.result = elems[3]
+	}
+
+	// a call that has been canceled is not pending any more
+	c.mu.Lock()
+	ch, ok := c.pending[msgid]
+	delete(c.pending, msgid)
+	c.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	return nil
+}
+
+// paramsArray returns params as an array, also when there are none.
+func paramsArray(params []interface{}) []interface{} {
+	if params == nil {
+		return []interface{}{}
+	}
+	return params
+}
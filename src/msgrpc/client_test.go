@@ -0,0 +1,170 @@
+package msgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "msgpack/src"
+)
+
+func newTestClient(t *testing.T) *Client {
+	client, server := net.Pipe()
+	go newTestServer(t).ServeConn(server)
+
+	c := NewClient(msgpack.NewMsgpack(), client)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCall(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	var sum int
+	require.NoError(t, c.Call(ctx, "add", &sum, 2, 3))
+	require.Equal(t, 5, sum)
+
+	var p point
+	require.NoError(t, c.Call(ctx, "move", &p, point{X: 1, Y: 2}, 3))
+	require.Equal(t, point{X: 4, Y: 2}, p)
+
+	// the result can be left out
+	require.NoError(t, c.Call(ctx, "add", nil, 1, 1))
+
+	err := c.Call(ctx, "move", &p, point{}, -1)
+	var rpcErr *Error
+	require.True(t, errors.As(err, &rpcErr), "got %v", err)
+	require.Equal(t, "negative move", rpcErr.Error())
+
+	err = c.Call(ctx, "fail", nil)
+	require.True(t, errors.As(err, &rpcErr), "got %v", err)
+	require.Equal(t, map[string]interface{}{"code": 7}, rpcErr.Value)
+
+	require.NoError(t, c.Notify("add", 1, 2))
+}
+
+func TestCallHandlerFailures(t *testing.T) {
+	c := newTestClient(t)
+	ctx := context.Background()
+
+	// an *Error without a value is still an error
+	err := c.Call(ctx, "failNil", nil)
+	var rpcErr *Error
+	require.True(t, errors.As(err, &rpcErr), "got %v", err)
+	require.Equal(t, "unknown error", rpcErr.Value)
+
+	// a panic is returned as an error and the server keeps running
+	var n int
+	err = c.Call(ctx, "crash", &n, "boom")
+	require.True(t, errors.As(err, &rpcErr), "got %v", err)
+	require.Equal(t, "crash: panic: boom", rpcErr.Error())
+
+	require.NoError(t, c.Notify("crash", "boom"))
+
+	var sum int
+	require.NoError(t, c.Call(ctx, "add", &sum, 1, 2))
+	require.Equal(t, 3, sum)
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	c := newTestClient(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var sum int
+			require.NoError(t, c.Call(context.Background(), "add", &sum, i, i))
+			require.Equal(t, 2*i, sum)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestCallCanceled(t *testing.T) {
+	c := newTestClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.Equal(t, context.DeadlineExceeded, c.Call(ctx, "wait", nil))
+
+	// the connection stays usable
+	var sum int
+	require.NoError(t, c.Call(context.Background(), "add", &sum, 1, 2))
+	require.Equal(t, 3, sum)
+
+	require.Equal(t, context.Canceled, c.Call(canceledContext(), "add", &sum, 1, 2))
+}
+
+func TestClientClose(t *testing.T) {
+	c := newTestClient(t)
+
+	done := make(chan error, 1)
+	go func() { done <- c.Call(context.Background(), "wait", nil) }()
+
+	// let the call get in flight
+	var sum int
+	require.NoError(t, c.Call(context.Background(), "add", &sum, 1, 2))
+
+	require.NoError(t, c.Close())
+	require.True(t, errors.Is(<-done, ErrClosed))
+	require.True(t, errors.Is(c.Call(context.Background(), "add", &sum, 1, 2), ErrClosed))
+	require.True(t, errors.Is(c.Notify("add", 1, 2), ErrClosed))
+}
+
+func TestServerGone(t *testing.T) {
+	client, server := net.Pipe()
+	c := NewClient(msgpack.NewMsgpack(), client)
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- c.Call(context.Background(), "add", nil, 1, 2) }()
+
+	// read the request and hang up
+	buf := make([]byte, 64)
+	_, err := server.Read(buf)
+	require.NoError(t, err)
+	server.Close()
+
+	require.True(t, errors.Is(<-done, ErrClosed))
+}
+
+func TestDial(t *testing.T) {
+	s := newTestServer(t)
+
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(t.TempDir(), "rpc.sock")
+			}
+
+			l, err := net.Listen(network, address)
+			require.NoError(t, err)
+			defer l.Close()
+			go s.Serve(l)
+
+			c, err := Dial(context.Background(), msgpack.NewMsgpack(), network, l.Addr().String())
+			require.NoError(t, err)
+			defer c.Close()
+
+			var sum int
+			require.NoError(t, c.Call(context.Background(), "add", &sum, 20, 22))
+			require.Equal(t, 42, sum)
+		})
+	}
+}
+
+func canceledContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
@@ -0,0 +1,139 @@
+package msgrpc
+
+import (
+	"fmt"
+	"io"
+	"net/rpc"
+
+	msgpack "msgpack/src"
+)
+
+type clientCodec struct {
+	c      *conn
+	result msgpack.RawMessage
+}
+
+// NewClientCodec returns a net/rpc ClientCodec for conn using a Msgpack with the default options.
+func NewClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return NewClientCodecWithMsgpack(msgpack.NewMsgpack(), conn)
+}
+
+// NewClientCodecWithMsgpack is like NewClientCodec but encodes and decodes with m.
+func NewClientCodecWithMsgpack(m *msgpack.Msgpack, conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &clientCodec{c: newConn(m, conn)}
+}
+
+func (cc *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	return cc.c.write(requestType, r.Seq, r.ServiceMethod, []interface{}{body})
+}
+
+func (cc *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	elems, err := cc.c.read()
+	if err != nil {
+		return err
+	}
+
+	typ, err := cc.c.messageType(elems)
+	if err != nil {
+		return err
+	}
+	if typ != responseType {
+		return fmt.Errorf("unexpected message of type %d", typ)
+	}
+
+	if err := cc.c.m.UnmarshalTo(elems[1], &r.Seq); err != nil {
+		return fmt.Errorf("invalid msgid: %w", err)
+	}
+
+	var errValue interface{}
+	if err := cc.c.m.UnmarshalTo(elems[2], &errValue); err != nil {
+		return fmt.Errorf("invalid error: %w", err)
+	}
+	if errValue != nil {
+		r.Error = (&Error{Value: errValue}).Error()
+	}
+
+	cc.result = elems[3]
+	return nil
+}
+
+func (cc *clientCodec) ReadResponseBody(body interface{}) error {
+	result := cc.result
+	cc.result = nil
+	if body == nil {
+		return nil
+	}
+	return cc.c.m.UnmarshalTo(result, body)
+}
+
+func (cc *clientCodec) Close() error {
+	return cc.c.rwc.Close()
+}
+
+type serverCodec struct {
+	c      *conn
+	params msgpack.RawMessage
+}
+
+// NewServerCodec returns a net/rpc ServerCodec for conn using a Msgpack with the default options.
+func NewServerCodec(conn io.ReadWriteCloser) rpc.ServerCodec {
+	return NewServerCodecWithMsgpack(msgpack.NewMsgpack(), conn)
+}
+
+// NewServerCodecWithMsgpack is like NewServerCodec but encodes and decodes with m.
+func NewServerCodecWithMsgpack(m *msgpack.Msgpack, conn io.ReadWriteCloser) rpc.ServerCodec {
+	return &serverCodec{c: newConn(m, conn)}
+}
+
+func (sc *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	elems, err := sc.c.read()
+	if err != nil {
+		return err
+	}
+
+	typ, err := sc.c.messageType(elems)
+	if err != nil {
+		return err
+	}
+	if typ != requestType {
+		return fmt.Errorf("unexpected message of type %d", typ)
+	}
+
+	if err := sc.c.m.UnmarshalTo(elems[1], &r.Seq); err != nil {
+		return fmt.Errorf("invalid msgid: %w", err)
+	}
+	if err := sc.c.m.UnmarshalTo(elems[2], &r.ServiceMethod); err != nil {
+		return fmt.Errorf("invalid method name: %w", err)
+	}
+
+	sc.params = elems[3]
+	return nil
+}
+
+func (sc *serverCodec) ReadRequestBody(body interface{}) error {
+	rawParams := sc.params
+	sc.params = nil
+	if body == nil {
+		return nil
+	}
+
+	var params []msgpack.RawMessage
+	if err := sc.c.m.UnmarshalTo(rawParams, &params); err != nil {
+		return fmt.Errorf("invalid params: %w", err)
+	}
+	if len(params) != 1 {
+		return fmt.Errorf("net/rpc methods take 1 param, got %d", len(params))
+	}
+	return sc.c.m.UnmarshalTo(params[0], body)
+}
+
+func (sc *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	if r.Error != "" {
+		return sc.c.write(responseType, r.Seq, r.Error, nil)
+	}
+	return sc.c.write(responseType, r.Seq, nil, body)
+}
+
+func (sc *serverCodec) Close() error {
+	return sc.c.rwc.Close()
+}
@@ -0,0 +1,103 @@
+package msgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "msgpack/src"
+)
+
+type Args struct {
+	A int `msgpack:"a"`
+	B int `msgpack:"b"`
+}
+
+type Arith struct{}
+
+func (Arith) Add(args Args, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+func (Arith) Div(args Args, reply *int) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+	*reply = args.A / args.B
+	return nil
+}
+
+func newArithServer(t *testing.T) *rpc.Server {
+	s := rpc.NewServer()
+	require.NoError(t, s.Register(Arith{}))
+	return s
+}
+
+func TestCodec(t *testing.T) {
+	client, server := net.Pipe()
+	go newArithServer(t).ServeCodec(NewServerCodec(server))
+
+	c := rpc.NewClientWithCodec(NewClientCodec(client))
+	defer c.Close()
+
+	var reply int
+	require.NoError(t, c.Call("Arith.Add", Args{A: 2, B: 3}, &reply))
+	require.Equal(t, 5, reply)
+
+	err := c.Call("Arith.Div", Args{A: 1}, &reply)
+	require.Equal(t, rpc.ServerError("divide by zero"), err)
+
+	err = c.Call("Arith.Mul", Args{}, &reply)
+	require.Error(t, err)
+
+	// concurrent calls
+	calls := make([]*rpc.Call, 20)
+	for i := range calls {
+		calls[i] = c.Go("Arith.Add", Args{A: i, B: i}, new(int), nil)
+	}
+	for i, call := range calls {
+		<-call.Done
+		require.NoError(t, call.Error)
+		require.Equal(t, 2*i, *call.Reply.(*int))
+	}
+}
+
+func TestCodecNativeClient(t *testing.T) {
+	client, server := net.Pipe()
+	go newArithServer(t).ServeCodec(NewServerCodec(server))
+
+	c := NewClient(msgpack.NewMsgpack(), client)
+	defer c.Close()
+
+	var reply int
+	require.NoError(t, c.Call(context.Background(), "Arith.Add", &reply, Args{A: 20, B: 22}))
+	require.Equal(t, 42, reply)
+
+	// net/rpc methods take exactly one argument
+	err := c.Call(context.Background(), "Arith.Add", &reply, Args{}, Args{})
+	var rpcErr *Error
+	require.True(t, errors.As(err, &rpcErr), "got %v", err)
+}
+
+func TestCodecWireFormat(t *testing.T) {
+	client, server := net.Pipe()
+	go newArithServer(t).ServeCodec(NewServerCodec(server))
+
+	// [0, 7, "Arith.Add", [{"a": 1, "b": 2}]]
+	_, err := client.Write([]byte{
+		0x94, 0x00, 0x07, 0xa9, 0x41, 0x72, 0x69, 0x74, 0x68, 0x2e, 0x41, 0x64, 0x64,
+		0x91, 0x82, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x02,
+	})
+	require.NoError(t, err)
+
+	buf := make([]byte, 16)
+	n, err := client.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x94, 0x01, 0x07, 0xc0, 0x03}, buf[:n])
+	client.Close()
+}
@@ -0,0 +1,127 @@
+// Package msgrpc implements the MessagePack-RPC protocol on top of the msgpack package.
+//
+// Messages are arrays written back to back on a stream connection, such as a TCP connection or a Unix socket:
+//
+//	[0, msgid, method, params]  request
+//	[1, msgid, error, result]   response
+//	[2, method, params]         notification
+//
+// A Server dispatches requests and notifications to registered Go functions,
+// a Client sends them and matches the responses to the calls in flight by their msgid.
+//
+// NewClientCodec and NewServerCodec let net/rpc clients and servers speak the same protocol instead of gob:
+//
+//	go rpc.ServeCodec(msgrpc.NewServerCodec(conn))
+//	client := rpc.NewClientWithCodec(msgrpc.NewClientCodec(conn))
+//
+// A call of a net/rpc method is a request whose params hold its single argument,
+// so net/rpc services can be called by any MessagePack-RPC client passing one param.
+package msgrpc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	msgpack "msgpack/src"
+)
+
+// Message types, the first element of every message.
+const (
+	requestType      = 0
+	responseType     = 1
+	notificationType = 2
+)
+
+// ErrClosed is returned by the calls of a Client whose connection is closed.
+var ErrClosed = errors.New("msgrpc: connection closed")
+
+// Error is the error element of a response.
+// A function returning an *Error sends its Value, other errors and an *Error with a nil Value are sent as their message.
+type Error struct {
+	Value interface{}
+}
+
+func (e *Error) Error() string {
+	if e.Value == nil {
+		return "unknown error"
+	}
+	if s, ok := e.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(e.Value)
+}
+
+// conn reads and writes whole messages on a stream.
+type conn struct {
+	m   *msgpack.Msgpack
+	rwc io.ReadWriteCloser
+	br  *bufio.Reader
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func newConn(m *msgpack.Msgpack, rwc io.ReadWriteCloser) *conn {
+	return &conn{m: m, rwc: rwc, br: bufio.NewReader(rwc)}
+}
+
+// write writes the message holding elems with a single Write call.
+func (c *conn) write(elems ...interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf, err := c.m.AppendMarshal(c.buf[:0], elems)
+	if err != nil {
+		return err
+	}
+	c.buf = buf[:0]
+
+	_, err = c.rwc.Write(buf)
+	return err
+}
+
+// read reads the next message and returns its elements.
+func (c *conn) read() ([]msgpack.RawMessage, error) {
+	raw, err := msgpack.ReadRaw(c.br, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var elems []msgpack.RawMessage
+	if err := c.m.UnmarshalTo(raw, &elems); err != nil {
+		return nil, fmt.Errorf("invalid message: %w", err)
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("invalid message: empty array")
+	}
+	return elems, nil
+}
+
+// messageType returns the type of the message holding elems.
+func (c *conn) messageType(elems []msgpack.RawMessage) (int, error) {
+	var typ int
+	if err := c.m.UnmarshalTo(elems[0], &typ); err != nil {
+		return 0, fmt.Errorf("invalid message type: %w", err)
+	}
+
+	switch {
+	case typ == requestType && len(elems) == 4,
+		typ == responseType && len(elems) == 4,
+		typ == notificationType && len(elems) == 3:
+		return typ, nil
+	}
+	return 0, fmt.Errorf("invalid message of type %d with %d elements", typ, len(elems))
+}
+
+// errorValue returns the error element of a response reporting err.
+// It is never nil, which would report success.
+func errorValue(err error) interface{} {
+	var rpcErr *Error
+	if errors.As(err, &rpcErr) && rpcErr.Value != nil {
+		return rpcErr.Value
+	}
+	return err.Error()
+}
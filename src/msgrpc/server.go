@@ -0,0 +1,221 @@
+package msgrpc
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"sync"
+
+	msgpack "msgpack/src"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Server dispatches MessagePack-RPC requests and notifications to registered functions.
+// It is safe for concurrent use.
+type Server struct {
+	m *msgpack.Msgpack
+
+	mu      sync.RWMutex
+	methods map[string]*method
+}
+
+// method is a registered function.
+type method struct {
+	fn        reflect.Value
+	hasCtx    bool
+	params    []reflect.Type
+	hasResult bool
+	hasErr    bool
+}
+
+// invoke calls the function with args, a panic is returned as an error instead of crashing the server.
+func (mt *method) invoke(args []reflect.Value) (out []reflect.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return mt.fn.Call(args), nil
+}
+
+// NewServer returns a Server that encodes and decodes messages with m.
+func NewServer(m *msgpack.Msgpack) *Server {
+	return &Server{m: m, methods: make(map[string]*method)}
+}
+
+// Register makes fn callable under name, replacing an earlier function of the same name.
+//
+// The parameters of fn, after an optional leading context.Context, are decoded from the params of a call,
+// which has to supply all of them. fn returns a result, an error, both in this order or nothing.
+// The context is canceled when the connection of the call closes.
+func (s *Server) Register(name string, fn interface{}) error {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func {
+		return fmt.Errorf("msgrpc: %s is not a function: %T", name, fn)
+	}
+
+	t := fv.Type()
+	if t.IsVariadic() {
+		return fmt.Errorf("msgrpc: %s is variadic", name)
+	}
+
+	mt := &method{fn: fv}
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && t.In(i) == contextType {
+			mt.hasCtx = true
+			continue
+		}
+		mt.params = append(mt.params, t.In(i))
+	}
+
+	switch {
+	case t.NumOut() == 0:
+	case t.NumOut() == 1 && t.Out(0) == errorType:
+		mt.hasErr = true
+	case t.NumOut() == 1:
+		mt.hasResult = true
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		mt.hasResult = true
+		mt.hasErr = true
+	default:
+		return fmt.Errorf("msgrpc: %s has to return a result, an error or both: %s", name, t)
+	}
+
+	s.mu.Lock()
+	s.methods[name] = mt
+	s.mu.Unlock()
+	return nil
+}
+
+// Serve accepts connections on l and serves each of them in its own goroutine.
+// It returns the error of Accept, once l is closed for example.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		rwc, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(rwc)
+	}
+}
+
+// ServeConn serves the requests and notifications on rwc, each in its own goroutine,
+// until rwc is closed or sends an invalid message. It then waits for the running calls and closes rwc.
+// It returns nil when rwc ends between messages.
+func (s *Server) ServeConn(rwc io.ReadWriteCloser) error {
+	c := newConn(s.m, rwc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+		rwc.Close()
+	}()
+
+	for {
+		elems, err := c.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		typ, err := c.messageType(elems)
+		if err != nil {
+			return err
+		}
+
+		switch typ {
+		case requestType:
+			var msgid uint32
+			if err := s.m.UnmarshalTo(elems[1], &msgid); err != nil {
+				return fmt.Errorf("invalid msgid: %w", err)
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := s.call(ctx, elems[2], elems[3])
+				if err != nil {
+					c.write(responseType, msgid, errorValue(err), nil)
+					return
+				}
+				c.write(responseType, msgid, nil, result)
+			}()
+
+		case notificationType:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.call(ctx, elems[1], elems[2])
+			}()
+
+		default:
+			return fmt.Errorf("unexpected message of type %d", typ)
+		}
+	}
+}
+
+// call calls the method named by rawMethod with the params in rawParams.
+func (s *Server) call(ctx context.Context, rawMethod, rawParams msgpack.RawMessage) (interface{}, error) {
+	var name string
+	if err := s.m.UnmarshalTo(rawMethod, &name); err != nil {
+		return nil, fmt.Errorf("invalid method name: %w", err)
+	}
+
+	s.mu.RLock()
+	mt, ok := s.methods[name]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("method not found: %s", name)
+	}
+
+	var params []msgpack.RawMessage
+	if err := s.m.UnmarshalTo(rawParams, &params); err != nil {
+		return nil, fmt.Errorf("invalid params of %s: %w", name, err)
+	}
+	if len(params) != len(mt.params) {
+		return nil, fmt.Errorf("%s takes %d params, got %d", name, len(mt.params), len(params))
+	}
+
+	// decode arguments
+	args := make([]reflect.Value, 0, len(params)+1)
+	if mt.hasCtx {
+		args = append(args, reflect.ValueOf(ctx))
+	}
+	for i, t := range mt.params {
+		arg := reflect.New(t)
+		if err := s.m.UnmarshalTo(params[i], arg.Interface()); err != nil {
+			return nil, fmt.Errorf("invalid param %d of %s: %w", i, name, err)
+		}
+		args = append(args, arg.Elem())
+	}
+
+	out, err := mt.invoke(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	if mt.hasErr {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+	}
+	if !mt.hasResult {
+		return nil, nil
+	}
+
+	// encode the result here to report the errors of doing so
+	result, err := s.m.AppendMarshal(nil, out[0].Interface())
+	if err != nil {
+		return nil, fmt.Errorf("encoding result of %s: %w", name, err)
+	}
+	return msgpack.RawMessage(result), nil
+}
@@ -0,0 +1,185 @@
+package msgrpc
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "msgpack/src"
+)
+
+type point struct {
+	X int `msgpack:"x"`
+	Y int `msgpack:"y"`
+}
+
+func newTestServer(t *testing.T) *Server {
+	s := NewServer(msgpack.NewMsgpack())
+	require.NoError(t, s.Register("add", func(a, b int) int { return a + b }))
+	require.NoError(t, s.Register("move", func(p point, dx int) (point, error) {
+		if dx < 0 {
+			return point{}, errors.New("negative move")
+		}
+		return point{X: p.X + dx, Y: p.Y}, nil
+	}))
+	require.NoError(t, s.Register("fail", func() error {
+		return &Error{Value: map[string]interface{}{"code": 7}}
+	}))
+	require.NoError(t, s.Register("failNil", func() error {
+		return &Error{}
+	}))
+	require.NoError(t, s.Register("crash", func(msg string) int {
+		panic(msg)
+	}))
+	require.NoError(t, s.Register("wait", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	return s
+}
+
+func TestServerMessages(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		request  []byte
+		expected []byte
+	}{
+		{
+			desc: "Test Case - request",
+			// [0, 1, "add", [2, 3]]
+			request:  []byte{0x94, 0x00, 0x01, 0xa3, 0x61, 0x64, 0x64, 0x92, 0x02, 0x03},
+			expected: []byte{0x94, 0x01, 0x01, 0xc0, 0x05},
+		},
+		{
+			desc: "Test Case - struct param",
+			// [0, 2, "move", [{"x": 1, "y": 2}, 3]]
+			request: []byte{
+				0x94, 0x00, 0x02, 0xa4, 0x6d, 0x6f, 0x76, 0x65,
+				0x92, 0x82, 0xa1, 0x78, 0x01, 0xa1, 0x79, 0x02, 0x03,
+			},
+			expected: []byte{0x94, 0x01, 0x02, 0xc0, 0x82, 0xa1, 0x78, 0x04, 0xa1, 0x79, 0x02},
+		},
+		{
+			desc: "Test Case - error",
+			// [0, 3, "move", [{}, -1]]
+			request: []byte{0x94, 0x00, 0x03, 0xa4, 0x6d, 0x6f, 0x76, 0x65, 0x92, 0x80, 0xff},
+			expected: []byte{
+				0x94, 0x01, 0x03,
+				0xad, 0x6e, 0x65, 0x67, 0x61, 0x74, 0x69, 0x76, 0x65, 0x20, 0x6d, 0x6f, 0x76, 0x65,
+				0xc0,
+			},
+		},
+		{
+			desc: "Test Case - error value",
+			// [0, 4, "fail", []]
+			request:  []byte{0x94, 0x00, 0x04, 0xa4, 0x66, 0x61, 0x69, 0x6c, 0x90},
+			expected: []byte{0x94, 0x01, 0x04, 0x81, 0xa4, 0x63, 0x6f, 0x64, 0x65, 0x07, 0xc0},
+		},
+		{
+			desc: "Test Case - unknown method",
+			// [0, 5, "x", []]
+			request: []byte{0x94, 0x00, 0x05, 0xa1, 0x78, 0x90},
+			expected: []byte{
+				0x94, 0x01, 0x05,
+				0xb3, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x20, 0x6e, 0x6f, 0x74, 0x20,
+				0x66, 0x6f, 0x75, 0x6e, 0x64, 0x3a, 0x20, 0x78,
+				0xc0,
+			},
+		},
+		{
+			desc: "Test Case - wrong number of params",
+			// [0, 6, "add", [1]]
+			request: []byte{0x94, 0x00, 0x06, 0xa3, 0x61, 0x64, 0x64, 0x91, 0x01},
+			expected: []byte{
+				0x94, 0x01, 0x06,
+				0xb9, 0x61, 0x64, 0x64, 0x20, 0x74, 0x61, 0x6b, 0x65, 0x73, 0x20, 0x32, 0x20,
+				0x70, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x2c, 0x20, 0x67, 0x6f, 0x74, 0x20, 0x31,
+				0xc0,
+			},
+		},
+	}
+
+	s := newTestServer(t)
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+			go s.ServeConn(server)
+
+			_, err := client.Write(tc.request)
+			require.NoError(t, err)
+
+			response, err := msgpack.ReadRaw(bufio.NewReader(client), 0)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, []byte(response))
+		})
+	}
+}
+
+func TestServerNotification(t *testing.T) {
+	s := NewServer(msgpack.NewMsgpack())
+	called := make(chan string, 1)
+	require.NoError(t, s.Register("log", func(msg string) { called <- msg }))
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go s.ServeConn(server)
+
+	// [2, "log", ["hi"]]
+	_, err := client.Write([]byte{0x93, 0x02, 0xa3, 0x6c, 0x6f, 0x67, 0x91, 0xa2, 0x68, 0x69})
+	require.NoError(t, err)
+	require.Equal(t, "hi", <-called)
+}
+
+func TestServerInvalidMessage(t *testing.T) {
+	s := newTestServer(t)
+
+	testCases := []struct {
+		desc    string
+		message []byte
+	}{
+		{
+			desc:    "Test Case - not an array",
+			message: []byte{0x01},
+		},
+		{
+			desc:    "Test Case - unknown type",
+			message: []byte{0x93, 0x05, 0xa1, 0x78, 0x90},
+		},
+		{
+			desc:    "Test Case - response sent to server",
+			message: []byte{0x94, 0x01, 0x00, 0xc0, 0xc0},
+		},
+		{
+			desc:    "Test Case - invalid msgid",
+			message: []byte{0x94, 0x00, 0xa1, 0x78, 0xa1, 0x78, 0x90},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer client.Close()
+
+			done := make(chan error, 1)
+			go func() { done <- s.ServeConn(server) }()
+
+			_, err := client.Write(tc.message)
+			require.NoError(t, err)
+			require.Error(t, <-done)
+		})
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	s := NewServer(msgpack.NewMsgpack())
+
+	require.Error(t, s.Register("a", 1))
+	require.Error(t, s.Register("b", func(...int) {}))
+	require.Error(t, s.Register("c", func() (int, int) { return 0, 0 }))
+	require.Error(t, s.Register("d", func() (error, int) { return nil, 0 }))
+}
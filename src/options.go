@@ -0,0 +1,191 @@
+package msgpack
+
+import "reflect"
+
+// DefaultTagName is the struct tag read for field names and flags unless WithTagName says otherwise.
+const DefaultTagName = "msgpack"
+
+// EncodeOptions controls how values are written.
+type EncodeOptions struct {
+	// InvalidUTF8 selects what happens to strings that are not valid UTF-8,
+	// UTF8AsBytes writes them as bin values. Map keys are never written as bin,
+	// UTF8AsBytes reports an invalid key as an error.
+	InvalidUTF8 UTF8Policy
+
+	// LegacyRaw writes strings and []byte with the raw types of the pre-2013 spec,
+	// fixraw, raw16 and raw32, for peers that know neither str8 nor bin.
+	LegacyRaw bool
+
+	// SortMapKeys writes map keys in sorted order so that equal maps give equal bytes.
+	SortMapKeys bool
+
+	// NarrowFloats writes a float64 as a float32 when the conversion loses nothing.
+	NarrowFloats bool
+
+	// OmitEmpty leaves out empty struct fields as if every field had the omitempty flag.
+	OmitEmpty bool
+
+	// NumberOverflow selects what happens to a json.Number that does not fit in the 64-bit numbers.
+	NumberOverflow NumberOverflow
+
+	// StructAsArray writes every struct as an array of its field values,
+	// as if it had the ",asarray" tag. Structs are decoded from maps and arrays either way.
+	StructAsArray bool
+}
+
+// NumberMode selects the Go type of numbers decoded into interface{} values.
+// Big numbers, the BigIntExtType and BigFloatExtType ext values, decode as *big.Int and *big.Float
+// except in the NumberFloat64 and NumberJSON modes.
+type NumberMode int
+
+const (
+	// NumberWireType decodes numbers into the Go type matching their encoding,
+	// int for fixint, uint8 for uint 8, float32 for float 32 and so on.
+	NumberWireType NumberMode = iota
+	// NumberInt64 decodes integers as int64, or uint64 above math.MaxInt64, and floats as float64.
+	NumberInt64
+	// NumberFloat64 decodes all numbers as float64, like encoding/json.
+	NumberFloat64
+	// NumberJSON decodes all numbers as json.Number.
+	NumberJSON
+)
+
+// DecodeOptions controls how untrusted input is decoded and limits the resources it may use.
+// A zero limit means no limit, except for MaxDepth which falls back to DefaultMaxDepth.
+// Whatever the options, a container is rejected before it is allocated
+// when it claims more elements than the remaining input can hold.
+type DecodeOptions struct {
+	// MaxDepth is the maximum nesting depth of arrays and maps.
+	MaxDepth int
+	// MaxContainerLen is the maximum number of elements of an array or key-value pairs of a map.
+	MaxContainerLen int
+	// MaxStringLen is the maximum length in bytes of a str value.
+	MaxStringLen int
+	// MaxBinLen is the maximum length in bytes of a bin or ext value.
+	MaxBinLen int
+	// MaxAllocBytes is the approximate number of bytes all decoded values may allocate in total.
+	MaxAllocBytes int
+
+	// InvalidUTF8 selects what happens to str values that are not valid UTF-8,
+	// UTF8AsBytes decodes them as []byte. Map keys stay strings, UTF8AsBytes keeps them as they are.
+	InvalidUTF8 UTF8Policy
+
+	// LegacyRaw decodes str values as []byte, the way the raw type of the pre-2013 spec
+	// carried both text and binary data. Map keys stay strings.
+	LegacyRaw bool
+
+	// NumberMode selects the Go type of numbers decoded into interface{} values.
+	NumberMode NumberMode
+
+	// MapType is the type of maps decoded into interface{} values, map[string]interface{} when nil.
+	// It has to be a map with string or interface{} keys, the latter also accepts keys that are not strings.
+	// Unmarshal always returns the top level map as a map[string]interface{}.
+	MapType reflect.Type
+
+	// DisallowUnknownFields reports map keys that match no field of the destination struct
+	// instead of skipping them.
+	DisallowUnknownFields bool
+
+	// AllowTrailingData makes Unmarshal and UnmarshalTo ignore the bytes after the first value
+	// instead of returning an error wrapping ErrTrailingData.
+	AllowTrailingData bool
+}
+
+// Option configures a Msgpack created by NewMsgpack.
+type Option func(*Msgpack)
+
+// WithEncodeOptions replaces the encode options.
+func WithEncodeOptions(opts EncodeOptions) Option {
+	return func(m *Msgpack) {
+		m.encodeOpts = opts
+	}
+}
+
+// WithDecodeOptions replaces the decode options.
+func WithDecodeOptions(opts DecodeOptions) Option {
+	return func(m *Msgpack) {
+		m.decodeOpts = opts
+	}
+}
+
+// WithSortMapKeys writes map keys in sorted order.
+func WithSortMapKeys() Option {
+	return func(m *Msgpack) {
+		m.encodeOpts.SortMapKeys = true
+	}
+}
+
+// WithNarrowFloats writes float64 values as float32 when the conversion loses nothing.
+func WithNarrowFloats() Option {
+	return func(m *Msgpack) {
+		m.encodeOpts.NarrowFloats = true
+	}
+}
+
+// WithOmitEmpty leaves out empty struct fields by default.
+func WithOmitEmpty() Option {
+	return func(m *Msgpack) {
+		m.encodeOpts.OmitEmpty = true
+	}
+}
+
+// WithStructAsArray writes structs as arrays of their field values.
+func WithStructAsArray() Option {
+	return func(m *Msgpack) {
+		m.encodeOpts.StructAsArray = true
+	}
+}
+
+// WithNumberOverflow selects what happens to a json.Number that does not fit in the 64-bit numbers.
+func WithNumberOverflow(mode NumberOverflow) Option {
+	return func(m *Msgpack) {
+		m.encodeOpts.NumberOverflow = mode
+	}
+}
+
+// WithTagName reads struct field names and flags from the tag name instead of DefaultTagName.
+func WithTagName(name string) Option {
+	return func(m *Msgpack) {
+		m.tagName = name
+	}
+}
+
+// WithNumberMode selects the Go type of numbers decoded into interface{} values.
+func WithNumberMode(mode NumberMode) Option {
+	return func(m *Msgpack) {
+		m.decodeOpts.NumberMode = mode
+	}
+}
+
+// WithMapType selects the type of maps decoded into interface{} values, see DecodeOptions.MapType.
+func WithMapType(t reflect.Type) Option {
+	return func(m *Msgpack) {
+		m.decodeOpts.MapType = t
+	}
+}
+
+// WithLimits sets the MaxDepth, MaxContainerLen, MaxStringLen, MaxBinLen and MaxAllocBytes
+// decode limits from limits, its other fields are ignored.
+func WithLimits(limits DecodeOptions) Option {
+	return func(m *Msgpack) {
+		m.decodeOpts.MaxDepth = limits.MaxDepth
+		m.decodeOpts.MaxContainerLen = limits.MaxContainerLen
+		m.decodeOpts.MaxStringLen = limits.MaxStringLen
+		m.decodeOpts.MaxBinLen = limits.MaxBinLen
+		m.decodeOpts.MaxAllocBytes = limits.MaxAllocBytes
+	}
+}
+
+// WithDisallowUnknownFields reports map keys that match no field of the destination struct.
+func WithDisallowUnknownFields() Option {
+	return func(m *Msgpack) {
+		m.decodeOpts.DisallowUnknownFields = true
+	}
+}
+
+// WithAllowTrailingData makes Unmarshal and UnmarshalTo ignore the bytes after the first value.
+func WithAllowTrailingData() Option {
+	return func(m *Msgpack) {
+		m.decodeOpts.AllowTrailingData = true
+	}
+}
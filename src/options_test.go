@@ -0,0 +1,228 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeInstanceOptions(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		opts     []Option
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc: "Test Case - sorted map keys",
+			opts: []Option{WithSortMapKeys()},
+			input: map[string]interface{}{
+				"c": 3, "a": 1, "b": 2,
+			},
+			expected: []byte{0x83, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x02, 0xa1, 0x63, 0x03},
+		},
+		{
+			desc: "Test Case - sorted map keys through reflection",
+			opts: []Option{WithSortMapKeys()},
+			input: map[string]int{
+				"c": 3, "a": 1, "b": 2,
+			},
+			expected: []byte{0x83, 0xa1, 0x61, 0x01, 0xa1, 0x62, 0x02, 0xa1, 0x63, 0x03},
+		},
+		{
+			desc:     "Test Case - narrowed float",
+			opts:     []Option{WithNarrowFloats()},
+			input:    1.5,
+			expected: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00},
+		},
+		{
+			desc:     "Test Case - float that does not narrow",
+			opts:     []Option{WithNarrowFloats()},
+			input:    0.1,
+			expected: []byte{0xcb, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+		},
+		{
+			desc:     "Test Case - narrowed json.Number",
+			opts:     []Option{WithNarrowFloats()},
+			input:    json.Number("1.5"),
+			expected: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00},
+		},
+		{
+			desc: "Test Case - omitempty default",
+			opts: []Option{WithOmitEmpty()},
+			input: struct {
+				A string
+				B int
+			}{B: 1},
+			expected: []byte{0x81, 0xa1, 0x42, 0x01},
+		},
+		{
+			desc: "Test Case - tag name",
+			opts: []Option{WithTagName("json")},
+			input: struct {
+				A int `json:"a" msgpack:"x"`
+			}{A: 1},
+			expected: []byte{0x81, 0xa1, 0x61, 0x01},
+		},
+		{
+			desc:     "Test Case - encode options",
+			opts:     []Option{WithEncodeOptions(EncodeOptions{LegacyRaw: true})},
+			input:    []byte{0x01},
+			expected: []byte{0xa1, 0x01},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := NewMsgpack(tc.opts...)
+
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestDecodeInstanceOptions(t *testing.T) {
+	// {"a": 1, "b": -1, "c": 1.5 as float32, "d": {"e": 255 as uint8}}
+	inputBytes := []byte{
+		0x84,
+		0xa1, 0x61, 0x01,
+		0xa1, 0x62, 0xff,
+		0xa1, 0x63, 0xca, 0x3f, 0xc0, 0x00, 0x00,
+		0xa1, 0x64, 0x81, 0xa1, 0x65, 0xcc, 0xff,
+	}
+
+	testCases := []struct {
+		desc     string
+		opts     []Option
+		expected map[string]interface{}
+	}{
+		{
+			desc: "Test Case - wire type numbers",
+			expected: map[string]interface{}{
+				"a": 1, "b": -1, "c": float32(1.5), "d": map[string]interface{}{"e": uint8(255)},
+			},
+		},
+		{
+			desc: "Test Case - int64 numbers",
+			opts: []Option{WithNumberMode(NumberInt64)},
+			expected: map[string]interface{}{
+				"a": int64(1), "b": int64(-1), "c": 1.5, "d": map[string]interface{}{"e": int64(255)},
+			},
+		},
+		{
+			desc: "Test Case - float64 numbers",
+			opts: []Option{WithNumberMode(NumberFloat64)},
+			expected: map[string]interface{}{
+				"a": 1.0, "b": -1.0, "c": 1.5, "d": map[string]interface{}{"e": 255.0},
+			},
+		},
+		{
+			desc: "Test Case - json.Number numbers",
+			opts: []Option{WithNumberMode(NumberJSON)},
+			expected: map[string]interface{}{
+				"a": json.Number("1"), "b": json.Number("-1"), "c": json.Number("1.5"),
+				"d": map[string]interface{}{"e": json.Number("255")},
+			},
+		},
+		{
+			desc: "Test Case - map type",
+			opts: []Option{WithMapType(reflect.TypeOf(map[interface{}]interface{}{}))},
+			expected: map[string]interface{}{
+				"a": 1, "b": -1, "c": float32(1.5), "d": map[interface{}]interface{}{"e": uint8(255)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := NewMsgpack(tc.opts...)
+
+			result, err := mp.Unmarshal(inputBytes)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestMapTypeNonStringKeys(t *testing.T) {
+	mp := NewMsgpack(WithMapType(reflect.TypeOf(map[interface{}]interface{}{})))
+
+	// [{1: "a"}]
+	var v interface{}
+	require.NoError(t, mp.UnmarshalTo([]byte{0x91, 0x81, 0x01, 0xa1, 0x61}, &v))
+	require.Equal(t, []interface{}{map[interface{}]interface{}{1: "a"}}, v)
+
+	// [{[]: "a"}]
+	err := mp.UnmarshalTo([]byte{0x91, 0x81, 0x90, 0xa1, 0x61}, &v)
+	require.Error(t, err)
+
+	mp = NewMsgpack(WithMapType(reflect.TypeOf(map[int]interface{}{})))
+	err = mp.UnmarshalTo([]byte{0x91, 0x81, 0x01, 0xa1, 0x61}, &v)
+	require.Error(t, err)
+}
+
+func TestNumberModeTypedDestination(t *testing.T) {
+	mp := NewMsgpack(WithNumberMode(NumberJSON))
+
+	var v struct {
+		A int
+		B float64
+		C string
+	}
+	inputBytes := []byte{0x83, 0xa1, 0x41, 0xff, 0xa1, 0x42, 0xca, 0x3f, 0xc0, 0x00, 0x00, 0xa1, 0x43, 0xa1, 0x78}
+	require.NoError(t, mp.UnmarshalTo(inputBytes, &v))
+	require.Equal(t, -1, v.A)
+	require.Equal(t, 1.5, v.B)
+	require.Equal(t, "x", v.C)
+}
+
+func TestWithLimits(t *testing.T) {
+	mp := NewMsgpack(
+		WithNumberMode(NumberInt64),
+		WithLimits(DecodeOptions{MaxContainerLen: 2, NumberMode: NumberFloat64}),
+	)
+
+	result, err := mp.Unmarshal([]byte{0x81, 0xa1, 0x61, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"a": int64(1)}, result)
+
+	_, err = mp.Unmarshal([]byte{0x81, 0xa1, 0x61, 0x93, 0x01, 0x02, 0x03})
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr), "expected a *LimitError, got %v", err)
+	require.Equal(t, "MaxContainerLen", limitErr.Limit)
+
+	// per call options replace those of the instance
+	_, err = mp.UnmarshalWithOptions([]byte{0x81, 0xa1, 0x61, 0x93, 0x01, 0x02, 0x03}, DecodeOptions{})
+	require.NoError(t, err)
+}
+
+func TestConcurrentInstance(t *testing.T) {
+	mp := NewMsgpack(WithSortMapKeys(), WithNumberMode(NumberInt64))
+
+	input := map[string]interface{}{"c": "x", "a": []interface{}{1.5, true}, "b": nil}
+	expected, err := mp.Marshal(input)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				result, err := mp.Marshal(input)
+				require.NoError(t, err)
+				require.Equal(t, expected, result)
+
+				_, err = mp.Unmarshal(result)
+				require.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,32 @@
+package msgpack
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// RawMessage is a raw encoded MessagePack value.
+// It can be used to delay decoding of a value or to precompute its encoding,
+// the same way json.RawMessage does for JSON.
+type RawMessage []byte
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+// encodeRawMessage writes the raw bytes verbatim, an empty RawMessage is written as nil.
+// The bytes must hold exactly one complete value, anything else would corrupt the enclosing message.
+func (m *Msgpack) encodeRawMessage(result *[]byte, raw RawMessage) error {
+	if len(raw) == 0 {
+		*result = AppendNil(*result)
+		return nil
+	}
+
+	n, err := Skip(raw, 0)
+	if err != nil {
+		return fmt.Errorf("invalid RawMessage: %w", err)
+	}
+	if n != len(raw) {
+		return fmt.Errorf("invalid RawMessage: %d bytes after the value", len(raw)-n)
+	}
+	*result = append(*result, raw...)
+	return nil
+}
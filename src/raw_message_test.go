@@ -0,0 +1,95 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawMessageMarshal(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		jsonObj  map[string]interface{}
+		expected []byte
+	}{
+		{
+			desc: "Test Case - raw array",
+			jsonObj: map[string]interface{}{
+				"payload": RawMessage{0x92, 0x01, 0xa1, 0x62},
+			},
+			expected: []byte{0x81, 0xa7, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x92, 0x01, 0xa1, 0x62},
+		},
+		{
+			desc: "Test Case - empty raw",
+			jsonObj: map[string]interface{}{
+				"payload": RawMessage{},
+			},
+			expected: []byte{0x81, 0xa7, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0xc0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m1, err := mp.Marshal(tc.jsonObj)
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expected, m1, "The two MessagePack byte should be equal")
+		})
+	}
+}
+
+func TestRawMessageMarshalInvalid(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc string
+		raw  RawMessage
+	}{
+		{
+			desc: "Test Case - truncated array",
+			raw:  RawMessage{0x92, 0x01},
+		},
+		{
+			desc: "Test Case - truncated string",
+			raw:  RawMessage{0xa3, 0x61},
+		},
+		{
+			desc: "Test Case - more than one value",
+			raw:  RawMessage{0x01, 0x02},
+		},
+		{
+			desc: "Test Case - never used byte",
+			raw:  RawMessage{0xc1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := mp.Marshal(map[string]interface{}{"payload": tc.raw})
+			require.ErrorContains(t, err, "invalid RawMessage")
+		})
+	}
+}
+
+func TestRawMessageUnmarshalTo(t *testing.T) {
+	mp := NewMsgpack()
+
+	// {"type": "a", "payload": [1, "b"]}
+	inputBytes := []byte{0x82, 0xa4, 0x74, 0x79, 0x70, 0x65, 0xa1, 0x61, 0xa7, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x92, 0x01, 0xa1, 0x62}
+
+	var envelope map[string]RawMessage
+	require.NoError(t, mp.UnmarshalTo(inputBytes, &envelope))
+	require.Equal(t, RawMessage{0xa1, 0x61}, envelope["type"])
+	require.Equal(t, RawMessage{0x92, 0x01, 0xa1, 0x62}, envelope["payload"])
+
+	var payload []interface{}
+	require.NoError(t, mp.UnmarshalTo(envelope["payload"], &payload))
+	require.Equal(t, []interface{}{1, "b"}, payload)
+
+	// round trip the raw payload into a new message
+	m1, err := mp.Marshal(map[string]interface{}{"payload": envelope["payload"]})
+	require.NoError(t, err)
+	require.Equal(t, inputBytes[8:], m1[1:])
+}
@@ -0,0 +1,138 @@
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrOverflow is returned when an encoded number does not fit in the requested Go type.
+var ErrOverflow = errors.New("value overflows the destination type")
+
+// ReadNil reads a nil value from data and returns the remaining bytes.
+func ReadNil(data []byte) ([]byte, error) {
+	_, rest, err := readKind(data, NilKind)
+	return rest, err
+}
+
+// ReadBool reads a boolean value from data.
+func ReadBool(data []byte) (bool, []byte, error) {
+	tok, rest, err := readKind(data, BoolKind)
+	return tok.Bool, rest, err
+}
+
+// ReadInt64 reads an integer of any encoding from data, values above math.MaxInt64 report ErrOverflow.
+func ReadInt64(data []byte) (int64, []byte, error) {
+	tok, rest, err := readNext(data)
+	if err != nil {
+		return 0, data, err
+	}
+
+	switch tok.Kind {
+	case IntKind:
+		return tok.Int, rest, nil
+	case UintKind:
+		if tok.Uint > math.MaxInt64 {
+			return 0, data, fmt.Errorf("%w: %d does not fit in int64", ErrOverflow, tok.Uint)
+		}
+		return int64(tok.Uint), rest, nil
+	}
+
+	return 0, data, fmt.Errorf("expected int, got %s", tok.Kind)
+}
+
+// ReadUint64 reads an integer of any encoding from data, negative values report ErrOverflow.
+func ReadUint64(data []byte) (uint64, []byte, error) {
+	tok, rest, err := readNext(data)
+	if err != nil {
+		return 0, data, err
+	}
+
+	switch tok.Kind {
+	case UintKind:
+		return tok.Uint, rest, nil
+	case IntKind:
+		if tok.Int < 0 {
+			return 0, data, fmt.Errorf("%w: %d does not fit in uint64", ErrOverflow, tok.Int)
+		}
+		return uint64(tok.Int), rest, nil
+	}
+
+	return 0, data, fmt.Errorf("expected uint, got %s", tok.Kind)
+}
+
+// ReadFloat64 reads a float32, a float64 or an integer from data as a float64.
+func ReadFloat64(data []byte) (float64, []byte, error) {
+	tok, rest, err := readNext(data)
+	if err != nil {
+		return 0, data, err
+	}
+
+	switch tok.Kind {
+	case FloatKind:
+		return tok.Float, rest, nil
+	case IntKind:
+		return float64(tok.Int), rest, nil
+	case UintKind:
+		return float64(tok.Uint), rest, nil
+	}
+
+	return 0, data, fmt.Errorf("expected float, got %s", tok.Kind)
+}
+
+// ReadString reads a str value from data.
+func ReadString(data []byte) (string, []byte, error) {
+	tok, rest, err := readKind(data, StrKind)
+	return string(tok.Bytes), rest, err
+}
+
+// ReadBytes reads a bin value from data. The returned bytes point into data.
+func ReadBytes(data []byte) ([]byte, []byte, error) {
+	tok, rest, err := readKind(data, BinKind)
+	return tok.Bytes, rest, err
+}
+
+// ReadArrayHeader reads an array header from data and returns the number of elements that follow it.
+func ReadArrayHeader(data []byte) (int, []byte, error) {
+	tok, rest, err := readKind(data, ArrayKind)
+	return tok.Length, rest, err
+}
+
+// ReadMapHeader reads a map header from data and returns the number of key-value pairs that follow it.
+func ReadMapHeader(data []byte) (int, []byte, error) {
+	tok, rest, err := readKind(data, MapKind)
+	return tok.Length, rest, err
+}
+
+// ReadExt reads an extension value from data. The returned Ext data points into data.
+func ReadExt(data []byte) (Ext, []byte, error) {
+	tok, rest, err := readKind(data, ExtKind)
+	return Ext{Type: tok.ExtType, Data: tok.Bytes}, rest, err
+}
+
+// readNext reads one token from data and returns the bytes after it.
+func readNext(data []byte) (Token, []byte, error) {
+	r := Reader{data: data}
+
+	tok, err := r.Next()
+	if err == io.EOF {
+		return Token{}, data, fmt.Errorf("data out of range")
+	}
+	if err != nil {
+		return Token{}, data, err
+	}
+	return tok, data[r.offset:], nil
+}
+
+// readKind reads one token of the given kind from data.
+func readKind(data []byte, kind Kind) (Token, []byte, error) {
+	tok, rest, err := readNext(data)
+	if err != nil {
+		return Token{}, data, err
+	}
+	if tok.Kind != kind {
+		return Token{}, data, fmt.Errorf("expected %s, got %s", kind, tok.Kind)
+	}
+	return tok, rest, nil
+}
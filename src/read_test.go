@@ -0,0 +1,139 @@
+package msgpack
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPrimitives(t *testing.T) {
+	var msg []byte
+	msg = AppendMapHeader(msg, 1)
+	msg = AppendString(msg, "data")
+	msg = AppendArrayHeader(msg, 7)
+	msg = AppendNil(msg)
+	msg = AppendBool(msg, true)
+	msg = AppendInt(msg, -300)
+	msg = AppendUint(msg, math.MaxUint64)
+	msg = AppendFloat32(msg, 1.5)
+	msg = AppendBytes(msg, []byte{0x01, 0x02})
+	msg = AppendExt(msg, 5, []byte{0x07})
+
+	mapLen, rest, err := ReadMapHeader(msg)
+	require.NoError(t, err)
+	require.Equal(t, 1, mapLen)
+
+	key, rest, err := ReadString(rest)
+	require.NoError(t, err)
+	require.Equal(t, "data", key)
+
+	arrLen, rest, err := ReadArrayHeader(rest)
+	require.NoError(t, err)
+	require.Equal(t, 7, arrLen)
+
+	rest, err = ReadNil(rest)
+	require.NoError(t, err)
+
+	b, rest, err := ReadBool(rest)
+	require.NoError(t, err)
+	require.True(t, b)
+
+	i, rest, err := ReadInt64(rest)
+	require.NoError(t, err)
+	require.Equal(t, int64(-300), i)
+
+	u, rest, err := ReadUint64(rest)
+	require.NoError(t, err)
+	require.Equal(t, uint64(math.MaxUint64), u)
+
+	f, rest, err := ReadFloat64(rest)
+	require.NoError(t, err)
+	require.Equal(t, 1.5, f)
+
+	bin, rest, err := ReadBytes(rest)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x02}, bin)
+
+	ext, rest, err := ReadExt(rest)
+	require.NoError(t, err)
+	require.Equal(t, Ext{Type: 5, Data: []byte{0x07}}, ext)
+
+	require.Empty(t, rest)
+}
+
+func TestReadIntegers(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		asInt      int64
+		intErr     error
+		asUint     uint64
+		uintErr    error
+	}{
+		{
+			desc:       "Test Case - positive fixint",
+			inputBytes: []byte{0x05},
+			asInt:      5,
+			asUint:     5,
+		},
+		{
+			desc:       "Test Case - int8 holding a positive value",
+			inputBytes: []byte{0xd0, 0x05},
+			asInt:      5,
+			asUint:     5,
+		},
+		{
+			desc:       "Test Case - uint16",
+			inputBytes: []byte{0xcd, 0x01, 0x00},
+			asInt:      256,
+			asUint:     256,
+		},
+		{
+			desc:       "Test Case - negative fixint",
+			inputBytes: []byte{0xfb},
+			asInt:      -5,
+			uintErr:    ErrOverflow,
+		},
+		{
+			desc:       "Test Case - uint64 above MaxInt64",
+			inputBytes: []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			intErr:     ErrOverflow,
+			asUint:     math.MaxUint64,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			i, _, err := ReadInt64(tc.inputBytes)
+			if tc.intErr != nil {
+				require.ErrorIs(t, err, tc.intErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.asInt, i)
+			}
+
+			u, _, err := ReadUint64(tc.inputBytes)
+			if tc.uintErr != nil {
+				require.ErrorIs(t, err, tc.uintErr)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.asUint, u)
+			}
+		})
+	}
+}
+
+func TestReadWrongType(t *testing.T) {
+	input := []byte{0xa3, 0x61, 0x73, 0x64}
+
+	_, rest, err := ReadInt64(input)
+	require.Error(t, err)
+	require.Equal(t, input, rest, "The input should be returned untouched on error")
+
+	_, _, err = ReadMapHeader(input)
+	require.Error(t, err)
+
+	_, _, err = ReadString(input[:2])
+	require.Error(t, err)
+}
@@ -0,0 +1,273 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	MsgPackTypes "msgpack/src/types"
+)
+
+// Kind identifies the kind of a Token read by a Reader.
+type Kind int
+
+const (
+	InvalidKind Kind = iota
+	NilKind
+	BoolKind
+	IntKind
+	UintKind
+	FloatKind
+	StrKind
+	BinKind
+	ArrayKind
+	MapKind
+	ExtKind
+)
+
+var kindNames = [...]string{
+	InvalidKind: "invalid",
+	NilKind:     "nil",
+	BoolKind:    "bool",
+	IntKind:     "int",
+	UintKind:    "uint",
+	FloatKind:   "float",
+	StrKind:     "str",
+	BinKind:     "bin",
+	ArrayKind:   "array",
+	MapKind:     "map",
+	ExtKind:     "ext",
+}
+
+func (k Kind) String() string {
+	if k < 0 || int(k) >= len(kindNames) {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+// Ext is a MessagePack extension value.
+type Ext struct {
+	Type int8
+	Data []byte
+}
+
+// Token is a single MessagePack token.
+// Str, bin and ext tokens keep their payload in Bytes, which points into the data of the Reader.
+// Array and map tokens are headers only, Length holds the number of elements or key-value pairs
+// that follow them.
+type Token struct {
+	Kind    Kind
+	Code    byte // type code, the first byte of the token
+	Bool    bool
+	Int     int64
+	Uint    uint64
+	Float   float64
+	Bytes   []byte
+	Length  int
+	ExtType int8
+}
+
+// Reader is a pull parser that reads MessagePack data one token at a time
+// without recursion and without allocating.
+type Reader struct {
+	data   []byte
+	offset int
+	opts   DecodeOptions
+
+	// state of the value decoding on top of the reader
+	depth     int
+	allocated int
+}
+
+// NewReader returns a new Reader reading from data.
+func NewReader(data []byte) *Reader {
+	return &Reader{data: data}
+}
+
+// NewReaderWithOptions returns a new Reader reading from data that rejects tokens exceeding the limits of opts.
+func NewReaderWithOptions(data []byte, opts DecodeOptions) *Reader {
+	return &Reader{data: data, opts: opts}
+}
+
+// Offset returns the position of the next token in the data.
+func (r *Reader) Offset() int {
+	return r.offset
+}
+
+// Next reads the next token. It returns io.EOF once all data has been read.
+// On error the Reader does not move.
+func (r *Reader) Next() (Token, error) {
+	if r.offset >= len(r.data) {
+		return Token{}, io.EOF
+	}
+
+	start := r.offset
+	currentByte := r.data[r.offset]
+	r.offset++
+
+	tok := Token{Code: currentByte}
+	var err error
+
+	switch {
+	case MsgPackTypes.IsMsgPackTypePositiveInt(currentByte):
+		tok.Kind = UintKind
+		tok.Uint = uint64(currentByte)
+
+	case MsgPackTypes.IsMsgPackTypeNegativeInt(currentByte):
+		tok.Kind = IntKind
+		tok.Int = int64(int8(currentByte))
+
+	case MsgPackTypes.IsMsgPackTypeString(currentByte):
+		tok.Kind = StrKind
+		tok.Bytes, err = r.readBytes(int(currentByte & 0x1F)) // 0x1F = 00011111
+
+	case MsgPackTypes.IsMsgPackTypeArray(currentByte):
+		tok.Kind = ArrayKind
+		tok.Length = int(currentByte & 0x0F) // 0x0F = 00001111
+
+	case MsgPackTypes.IsMsgPackTypeMap(currentByte):
+		tok.Kind = MapKind
+		tok.Length = int(currentByte & 0x0F) // 0x0F = 00001111
+
+	default:
+		err = r.readToken(&tok)
+	}
+
+	if err == nil {
+		err = r.checkLimits(&tok, start)
+	}
+
+	if err != nil {
+		r.offset = start
+		return Token{}, err
+	}
+
+	if tok.Kind == StrKind || tok.Kind == BinKind || tok.Kind == ExtKind {
+		tok.Length = len(tok.Bytes)
+	}
+	return tok, nil
+}
+
+// readToken reads the tokens whose type is given by the whole first byte.
+func (r *Reader) readToken(tok *Token) error {
+	var n int
+	var err error
+
+	switch tok.Code {
+	case MsgPackTypes.Nil:
+		tok.Kind = NilKind
+
+	case MsgPackTypes.False, MsgPackTypes.True:
+		tok.Kind = BoolKind
+		tok.Bool = tok.Code == MsgPackTypes.True
+
+	case MsgPackTypes.Uint8, MsgPackTypes.Uint16, MsgPackTypes.Uint32, MsgPackTypes.Uint64:
+		tok.Kind = UintKind
+		tok.Uint, err = r.readUint(1 << (tok.Code - MsgPackTypes.Uint8))
+
+	case MsgPackTypes.Int8, MsgPackTypes.Int16, MsgPackTypes.Int32, MsgPackTypes.Int64:
+		tok.Kind = IntKind
+		size := 1 << (tok.Code - MsgPackTypes.Int8)
+		var u uint64
+		u, err = r.readUint(size)
+		// sign extend
+		shift := 64 - 8*size
+		tok.Int = int64(u<<shift) >> shift
+
+	case MsgPackTypes.Float32:
+		tok.Kind = FloatKind
+		var u uint64
+		u, err = r.readUint(4)
+		tok.Float = float64(math.Float32frombits(uint32(u)))
+
+	case MsgPackTypes.Float64:
+		tok.Kind = FloatKind
+		var u uint64
+		u, err = r.readUint(8)
+		tok.Float = math.Float64frombits(u)
+
+	case MsgPackTypes.Str8, MsgPackTypes.Str16, MsgPackTypes.Str32:
+		tok.Kind = StrKind
+		if n, err = readLength(r.data, &r.offset, 1<<(tok.Code-MsgPackTypes.Str8)); err == nil {
+			tok.Bytes, err = r.readBytes(n)
+		}
+
+	case MsgPackTypes.Bin8, MsgPackTypes.Bin16, MsgPackTypes.Bin32:
+		tok.Kind = BinKind
+		if n, err = readLength(r.data, &r.offset, 1<<(tok.Code-MsgPackTypes.Bin8)); err == nil {
+			tok.Bytes, err = r.readBytes(n)
+		}
+
+	case MsgPackTypes.Array16, MsgPackTypes.Array32:
+		tok.Kind = ArrayKind
+		tok.Length, err = readLength(r.data, &r.offset, 2<<(tok.Code-MsgPackTypes.Array16))
+
+	case MsgPackTypes.Map16, MsgPackTypes.Map32:
+		tok.Kind = MapKind
+		tok.Length, err = readLength(r.data, &r.offset, 2<<(tok.Code-MsgPackTypes.Map16))
+
+	case MsgPackTypes.FixExt1, MsgPackTypes.FixExt2, MsgPackTypes.FixExt4, MsgPackTypes.FixExt8, MsgPackTypes.FixExt16:
+		tok.Kind = ExtKind
+		err = r.readExt(tok, 1<<(tok.Code-MsgPackTypes.FixExt1))
+
+	case MsgPackTypes.Ext8, MsgPackTypes.Ext16, MsgPackTypes.Ext32:
+		tok.Kind = ExtKind
+		if n, err = readLength(r.data, &r.offset, 1<<(tok.Code-MsgPackTypes.Ext8)); err == nil {
+			err = r.readExt(tok, n)
+		}
+
+	default:
+		return fmt.Errorf("unknown type 0x%02x at offset %d", tok.Code, r.offset-1)
+	}
+
+	return err
+}
+
+// readExt reads the type byte and the n data bytes of an ext token.
+func (r *Reader) readExt(tok *Token, n int) error {
+	if r.offset >= len(r.data) {
+		return fmt.Errorf("data out of range")
+	}
+	tok.ExtType = int8(r.data[r.offset])
+	r.offset++
+
+	var err error
+	tok.Bytes, err = r.readBytes(n)
+	return err
+}
+
+// readBytes returns the next n bytes without copying them.
+func (r *Reader) readBytes(n int) ([]byte, error) {
+	if n < 0 || r.offset+n > len(r.data) {
+		return nil, fmt.Errorf("insufficient data for %d bytes", n)
+	}
+
+	value := r.data[r.offset : r.offset+n : r.offset+n]
+	r.offset += n
+
+	return value, nil
+}
+
+// readUint reads a big-endian unsigned integer of size bytes.
+func (r *Reader) readUint(size int) (uint64, error) {
+	if r.offset+size > len(r.data) {
+		return 0, fmt.Errorf("data out of range")
+	}
+
+	var value uint64
+	switch size {
+	case 1:
+		value = uint64(r.data[r.offset])
+	case 2:
+		value = uint64(binary.BigEndian.Uint16(r.data[r.offset:]))
+	case 4:
+		value = uint64(binary.BigEndian.Uint32(r.data[r.offset:]))
+	case 8:
+		value = binary.BigEndian.Uint64(r.data[r.offset:])
+	}
+	r.offset += size
+
+	return value, nil
+}
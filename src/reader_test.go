@@ -0,0 +1,81 @@
+package msgpack
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// {"a": [nil, true, -5, 200, 1.5, "hi", <bin 0x01>, <ext 5 0x07>], "b": int16(-300)}
+var readerInputBytes = []byte{
+	0x82,
+	0xa1, 0x61, 0x98,
+	0xc0, 0xc3, 0xfb, 0xcc, 0xc8,
+	0xcb, 0x3f, 0xf8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xd9, 0x02, 0x68, 0x69,
+	0xc4, 0x01, 0x01,
+	0xd4, 0x05, 0x07,
+	0xa1, 0x62, 0xd1, 0xfe, 0xd4,
+}
+
+func TestReaderNext(t *testing.T) {
+	expected := []Token{
+		{Kind: MapKind, Code: 0x82, Length: 2},
+		{Kind: StrKind, Code: 0xa1, Bytes: []byte("a"), Length: 1},
+		{Kind: ArrayKind, Code: 0x98, Length: 8},
+		{Kind: NilKind, Code: 0xc0},
+		{Kind: BoolKind, Code: 0xc3, Bool: true},
+		{Kind: IntKind, Code: 0xfb, Int: -5},
+		{Kind: UintKind, Code: 0xcc, Uint: 200},
+		{Kind: FloatKind, Code: 0xcb, Float: 1.5},
+		{Kind: StrKind, Code: 0xd9, Bytes: []byte("hi"), Length: 2},
+		{Kind: BinKind, Code: 0xc4, Bytes: []byte{0x01}, Length: 1},
+		{Kind: ExtKind, Code: 0xd4, Bytes: []byte{0x07}, Length: 1, ExtType: 5},
+		{Kind: StrKind, Code: 0xa1, Bytes: []byte("b"), Length: 1},
+		{Kind: IntKind, Code: 0xd1, Int: -300},
+	}
+
+	r := NewReader(readerInputBytes)
+	for _, want := range expected {
+		tok, err := r.Next()
+		require.NoError(t, err)
+		require.Equal(t, want, tok)
+	}
+
+	_, err := r.Next()
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, len(readerInputBytes), r.Offset())
+}
+
+func TestReaderNextTruncated(t *testing.T) {
+	r := NewReader([]byte{0xd9, 0x05, 0x68})
+
+	_, err := r.Next()
+	require.Error(t, err)
+	require.Equal(t, 0, r.Offset(), "The reader should not move on error")
+}
+
+func TestReaderNextAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		r := Reader{data: readerInputBytes}
+		for {
+			if _, err := r.Next(); err != nil {
+				break
+			}
+		}
+	})
+	require.Zero(t, allocs)
+}
+
+func TestUnmarshalAllFormats(t *testing.T) {
+	mp := NewMsgpack()
+
+	output, err := mp.Unmarshal(readerInputBytes)
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]interface{}{
+		"a": []interface{}{nil, true, -5, uint8(200), 1.5, "hi", []byte{0x01}, Ext{Type: 5, Data: []byte{0x07}}},
+		"b": int16(-300),
+	}, output)
+}
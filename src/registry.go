@@ -0,0 +1,167 @@
+package msgpack
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Keys of the map that wraps a value of a registered type, {"$type": name, "value": value}.
+const (
+	typeKey  = "$type"
+	valueKey = "value"
+)
+
+// RegisterName records the concrete type of proto under name. A value of that type held in an interface,
+// such as a struct field, slice element or map value of interface type, is written together with name,
+// as the map {"$type": name, "value": value} or as an ext value when WithTypeExt is set.
+// Decoding into an interface creates a value of the registered type again.
+// Generic decoding into interface{} values, as done by Unmarshal, keeps the wrapping as it is.
+//
+// Like encoding/gob, RegisterName panics when name or the type is already registered differently.
+// Types should be registered before m is used: registering while other goroutines encode or decode
+// with m does not race, but whether their values of the type are wrapped is undefined.
+func (m *Msgpack) RegisterName(name string, proto interface{}) {
+	t := reflect.TypeOf(proto)
+	if t == nil {
+		panic("msgpack: RegisterName of nil value")
+	}
+
+	if prev, ok := m.namedTypes.Load(name); ok && prev != t {
+		panic(fmt.Sprintf("msgpack: registering duplicate types for %q: %s != %s", name, prev, t))
+	}
+	if prev, ok := m.typeNames.Load(t); ok && prev != name {
+		panic(fmt.Sprintf("msgpack: registering duplicate names for %s: %q != %q", t, prev, name))
+	}
+
+	m.namedTypes.Store(name, t)
+	m.typeNames.Store(t, name)
+	m.registered.Store(true)
+}
+
+// WithTypeExt writes values of registered types as ext values of type code,
+// their data is the name as a str value followed by the value.
+func WithTypeExt(code int8) Option {
+	return func(m *Msgpack) {
+		m.typeExt = true
+		m.typeExtCode = code
+	}
+}
+
+// encodeInterface writes a value held in an interface, wrapping it with its name when its type is registered.
+func (m *Msgpack) encodeInterface(result *[]byte, v interface{}, opts *encodeState) error {
+	if v == nil || !m.registered.Load() {
+		return m.handleValue(result, v, opts)
+	}
+
+	name, ok := m.typeNames.Load(reflect.TypeOf(v))
+	if !ok {
+		return m.handleValue(result, v, opts)
+	}
+
+	if m.typeExt {
+		var data []byte
+		if err := m.encodeString(&data, name.(string), opts); err != nil {
+			return err
+		}
+		if err := m.handleValue(&data, v, opts); err != nil {
+			return err
+		}
+		*result = AppendExt(*result, m.typeExtCode, data)
+		return nil
+	}
+
+	// add type prefix
+	*result = AppendMapHeader(*result, 2)
+
+	// add value
+	if err := m.encodeMapKey(result, typeKey, opts); err != nil {
+		return err
+	}
+	if err := m.encodeString(result, name.(string), opts); err != nil {
+		return err
+	}
+	if err := m.encodeMapKey(result, valueKey, opts); err != nil {
+		return err
+	}
+	return m.handleValue(result, v, opts)
+}
+
+// decodeNamed decodes a wrapped value of a registered type into the interface rv.
+// It reports false and leaves r where it was when the next value is not wrapped.
+func (m *Msgpack) decodeNamed(r *Reader, rv reflect.Value) (bool, error) {
+	start := r.offset
+
+	tok, err := r.Next()
+	if err == io.EOF {
+		return false, fmt.Errorf("data out of range")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case tok.Kind == ExtKind && m.typeExt && tok.ExtType == m.typeExtCode:
+		// the name and the value are read from the ext data, with the limits and state of r
+		sub := Reader{data: tok.Bytes, opts: r.opts, depth: r.depth, allocated: r.allocated}
+		err := m.decodeNamedValue(&sub, rv, r.offset-len(tok.Bytes), false)
+		r.allocated = sub.allocated
+		return true, err
+
+	case tok.Kind == MapKind && tok.Length == 2:
+		if key, err := r.Next(); err == nil && key.Kind == StrKind && string(key.Bytes) == typeKey {
+			if err := r.enter(); err != nil {
+				return true, err
+			}
+			defer r.leave()
+			return true, m.decodeNamedValue(r, rv, 0, true)
+		}
+	}
+
+	r.offset = start
+	return false, nil
+}
+
+// decodeNamedValue reads a name and a value of the type registered under it into rv,
+// with the value key in between for the map form. base is the offset of r.data in the input for error messages.
+func (m *Msgpack) decodeNamedValue(r *Reader, rv reflect.Value, base int, mapForm bool) error {
+	offset := base + r.Offset()
+
+	tok, err := r.Next()
+	if err == io.EOF {
+		return fmt.Errorf("data out of range")
+	}
+	if err != nil {
+		return err
+	}
+	if tok.Kind != StrKind {
+		return fmt.Errorf("expected type name at offset %d, got %s", offset, tok.Kind)
+	}
+
+	t, ok := m.namedTypes.Load(string(tok.Bytes))
+	if !ok {
+		return fmt.Errorf("unknown type name %q at offset %d", tok.Bytes, offset)
+	}
+	typ := t.(reflect.Type)
+	if !typ.AssignableTo(rv.Type()) {
+		return fmt.Errorf("type %s registered as %q does not implement %s", typ, tok.Bytes, rv.Type())
+	}
+
+	if mapForm {
+		offset = r.Offset()
+		key, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if key.Kind != StrKind || string(key.Bytes) != valueKey {
+			return fmt.Errorf("expected %q key at offset %d", valueKey, offset)
+		}
+	}
+
+	value := reflect.New(typ).Elem()
+	if err := m.decodeValue(r, value); err != nil {
+		return err
+	}
+	rv.Set(value)
+	return nil
+}
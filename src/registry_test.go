@@ -0,0 +1,190 @@
+package msgpack
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type registryTestShape interface {
+	Area() float64
+}
+
+type registryTestCircle struct {
+	R float64 `msgpack:"r"`
+}
+
+func (c registryTestCircle) Area() float64 { return 3 * c.R * c.R }
+
+type registryTestSquare struct {
+	Side float64 `msgpack:"side"`
+}
+
+func (s registryTestSquare) Area() float64 { return s.Side * s.Side }
+
+type registryTestDrawing struct {
+	Main   registryTestShape            `msgpack:"main"`
+	Shapes []registryTestShape          `msgpack:"shapes"`
+	ByName map[string]registryTestShape `msgpack:"by_name"`
+	Any    interface{}                  `msgpack:"any"`
+}
+
+func newRegistryTestMsgpack(opts ...Option) *Msgpack {
+	mp := NewMsgpack(opts...)
+	mp.RegisterName("circle", registryTestCircle{})
+	mp.RegisterName("square", registryTestSquare{})
+	return mp
+}
+
+func TestRegisterNameEncoding(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		opts     []Option
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc:  "Test Case - map form",
+			input: []registryTestShape{registryTestSquare{Side: 0.5}},
+			expected: []byte{
+				0x91, 0x82,
+				0xa5, 0x24, 0x74, 0x79, 0x70, 0x65, 0xa6, 0x73, 0x71, 0x75, 0x61, 0x72, 0x65,
+				0xa5, 0x76, 0x61, 0x6c, 0x75, 0x65,
+				0x81, 0xa4, 0x73, 0x69, 0x64, 0x65, 0xcb, 0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			desc:  "Test Case - ext form",
+			opts:  []Option{WithTypeExt(5)},
+			input: []registryTestShape{registryTestSquare{Side: 0.5}},
+			expected: []byte{
+				0x91, 0xc7, 0x16, 0x05,
+				0xa6, 0x73, 0x71, 0x75, 0x61, 0x72, 0x65,
+				0x81, 0xa4, 0x73, 0x69, 0x64, 0x65, 0xcb, 0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+		},
+		{
+			desc:     "Test Case - concrete slice is not wrapped",
+			input:    []registryTestCircle{{R: 0.5}},
+			expected: []byte{0x91, 0x81, 0xa1, 0x72, 0xcb, 0x3f, 0xe0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := newRegistryTestMsgpack(tc.opts...)
+
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestRegisterNameRoundTrip(t *testing.T) {
+	input := registryTestDrawing{
+		Main:   registryTestCircle{R: 1},
+		Shapes: []registryTestShape{registryTestSquare{Side: 2}, nil, registryTestCircle{R: 3}},
+		ByName: map[string]registryTestShape{"a": registryTestSquare{Side: 4}},
+		Any:    registryTestCircle{R: 5},
+	}
+
+	testCases := []struct {
+		desc string
+		opts []Option
+	}{
+		{
+			desc: "Test Case - map form",
+		},
+		{
+			desc: "Test Case - ext form",
+			opts: []Option{WithTypeExt(5)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := newRegistryTestMsgpack(tc.opts...)
+
+			encoded, err := mp.AppendMarshal(nil, input)
+			require.NoError(t, err)
+
+			var result registryTestDrawing
+			require.NoError(t, mp.UnmarshalTo(encoded, &result))
+			require.Equal(t, input, result)
+		})
+	}
+}
+
+func TestRegisterNameDecodeErrors(t *testing.T) {
+	mp := newRegistryTestMsgpack()
+
+	testCases := []struct {
+		desc  string
+		input map[string]interface{}
+	}{
+		{
+			desc:  "Test Case - unknown name",
+			input: map[string]interface{}{"main": map[string]interface{}{"$type": "triangle", "value": nil}},
+		},
+		{
+			desc:  "Test Case - missing value key",
+			input: map[string]interface{}{"main": map[string]interface{}{"$type": "circle", "data": nil}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			encoded, err := NewMsgpack(WithSortMapKeys()).Marshal(tc.input)
+			require.NoError(t, err)
+
+			var result registryTestDrawing
+			require.Error(t, mp.UnmarshalTo(encoded, &result))
+		})
+	}
+
+	// a registered type that does not implement the destination interface
+	mp.RegisterName("number", 0)
+	encoded, err := mp.AppendMarshal(nil, map[string]interface{}{"main": 1})
+	require.NoError(t, err)
+
+	var result registryTestDrawing
+	require.Error(t, mp.UnmarshalTo(encoded, &result))
+}
+
+func TestRegisterNameDuplicate(t *testing.T) {
+	mp := newRegistryTestMsgpack()
+
+	// registering the same pair again is fine
+	mp.RegisterName("circle", registryTestCircle{})
+
+	require.Panics(t, func() { mp.RegisterName("circle", registryTestSquare{}) })
+	require.Panics(t, func() { mp.RegisterName("round", registryTestCircle{}) })
+	require.Panics(t, func() { mp.RegisterName("nil", nil) })
+}
+
+func TestRegisterNameEncodeOptions(t *testing.T) {
+	long := string(bytes.Repeat([]byte{0x61}, 40))
+
+	// the name is written as raw16 instead of str8
+	mp := NewMsgpack(WithEncodeOptions(EncodeOptions{LegacyRaw: true}))
+	mp.RegisterName(long, registryTestCircle{})
+
+	encoded, err := mp.AppendMarshal(nil, []registryTestShape{registryTestCircle{}})
+	require.NoError(t, err)
+	expected := append([]byte{0x91, 0x82, 0xa5, 0x24, 0x74, 0x79, 0x70, 0x65, 0xda, 0x00, 0x28}, long...)
+	require.Equal(t, expected, encoded[:len(expected)])
+
+	var shapes []registryTestShape
+	require.NoError(t, mp.UnmarshalTo(encoded, &shapes))
+	require.Equal(t, []registryTestShape{registryTestCircle{}}, shapes)
+
+	// invalid names are reported
+	for _, opt := range []Option{WithTypeExt(1), func(*Msgpack) {}} {
+		mp = NewMsgpack(WithEncodeOptions(EncodeOptions{InvalidUTF8: UTF8Error}), opt)
+		mp.RegisterName("a\xffb", registryTestCircle{})
+		_, err = mp.AppendMarshal(nil, []registryTestShape{registryTestCircle{}})
+		require.ErrorIs(t, err, ErrInvalidUTF8)
+	}
+}
@@ -0,0 +1,48 @@
+package msgpack
+
+import (
+	"fmt"
+	"io"
+)
+
+// Skip returns the offset right after the value that starts at data[offset].
+// Values of any type are stepped over, nested containers, bin and ext included,
+// without decoding them and without allocating.
+func Skip(data []byte, offset int) (next int, err error) {
+	if offset < 0 || offset > len(data) {
+		return offset, fmt.Errorf("offset %d out of range", offset)
+	}
+
+	r := Reader{data: data, offset: offset}
+	if err := r.Skip(); err != nil {
+		return offset, err
+	}
+	return r.offset, nil
+}
+
+// Skip moves the reader past the next complete value.
+// On error the Reader does not move.
+func (r *Reader) Skip() error {
+	start := r.offset
+
+	// number of values left to skip, containers add their elements to it
+	for n := 1; n > 0; n-- {
+		tok, err := r.Next()
+		if err == io.EOF {
+			err = fmt.Errorf("data out of range")
+		}
+		if err != nil {
+			r.offset = start
+			return err
+		}
+
+		switch tok.Kind {
+		case ArrayKind:
+			n += tok.Length
+		case MapKind:
+			n += 2 * tok.Length
+		}
+	}
+
+	return nil
+}
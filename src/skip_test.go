@@ -0,0 +1,107 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkip(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		offset     int
+		expected   int
+	}{
+		{
+			desc:       "Test Case - positive fixint",
+			inputBytes: []byte{0x05, 0xc0},
+			expected:   1,
+		},
+		{
+			desc:       "Test Case - float64",
+			inputBytes: []byte{0xcb, 0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18, 0xc0},
+			expected:   9,
+		},
+		{
+			desc:       "Test Case - str8",
+			inputBytes: []byte{0xd9, 0x03, 0x61, 0x62, 0x63, 0xc0},
+			expected:   5,
+		},
+		{
+			desc:       "Test Case - bin16",
+			inputBytes: []byte{0xc5, 0x00, 0x02, 0x01, 0x02, 0xc0},
+			expected:   5,
+		},
+		{
+			desc:       "Test Case - fixext4",
+			inputBytes: []byte{0xd6, 0x01, 0x00, 0x00, 0x00, 0x01, 0xc0},
+			expected:   6,
+		},
+		{
+			desc:       "Test Case - ext8",
+			inputBytes: []byte{0xc7, 0x03, 0x01, 0x61, 0x62, 0x63, 0xc0},
+			expected:   6,
+		},
+		{
+			desc:       "Test Case - nested containers",
+			inputBytes: []byte{0x82, 0xa1, 0x61, 0x92, 0x01, 0x81, 0xa1, 0x62, 0xc3, 0xa1, 0x63, 0xdc, 0x00, 0x01, 0x90, 0xc0},
+			expected:   15,
+		},
+		{
+			desc:       "Test Case - from offset",
+			inputBytes: []byte{0x92, 0xa1, 0x61, 0xcd, 0x01, 0x00},
+			offset:     3,
+			expected:   6,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			next, err := Skip(tc.inputBytes, tc.offset)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, next)
+		})
+	}
+}
+
+func TestSkipTruncated(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+	}{
+		{
+			desc:       "Test Case - empty",
+			inputBytes: []byte{},
+		},
+		{
+			desc:       "Test Case - missing array element",
+			inputBytes: []byte{0x92, 0x01},
+		},
+		{
+			desc:       "Test Case - short bin",
+			inputBytes: []byte{0xc4, 0x05, 0x01},
+		},
+		{
+			desc:       "Test Case - unknown type",
+			inputBytes: []byte{0xc1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			next, err := Skip(tc.inputBytes, 0)
+			require.Error(t, err)
+			require.Equal(t, 0, next)
+		})
+	}
+}
+
+func TestSkipAllocs(t *testing.T) {
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := Skip(readerInputBytes, 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	require.Zero(t, allocs)
+}
@@ -0,0 +1,191 @@
+// Package stdext provides msgpack extensions for common standard library types.
+//
+// It is opt-in, register the extensions on an instance with
+//
+//	mp := msgpack.NewMsgpack(stdext.Option())
+//
+// Values of the types below are then written as ext values of the listed type codes
+// and decode into the same Go types, also into interface{} values:
+//
+//	DurationCode   time.Duration  nanoseconds as a big-endian int64
+//	IPCode         net.IP         4 bytes for IPv4 addresses, 16 bytes otherwise
+//	AddrCode       netip.Addr     the netip.Addr.MarshalBinary form
+//	PrefixCode     netip.Prefix   the netip.Prefix.MarshalBinary form
+//	URLCode        *url.URL       the URL string
+//	Complex64Code  complex64      real and imaginary part as big-endian float32
+//	Complex128Code complex128     real and imaginary part as big-endian float64
+//
+// Peers have to agree on the codes, Extensions lists them for use with msgpack.WithExtensions.
+package stdext
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"time"
+
+	msgpack "msgpack/src"
+)
+
+// Ext type codes of the standard library types.
+const (
+	DurationCode   int8 = 16
+	IPCode         int8 = 17
+	AddrCode       int8 = 18
+	PrefixCode     int8 = 19
+	URLCode        int8 = 20
+	Complex64Code  int8 = 21
+	Complex128Code int8 = 22
+)
+
+// Option registers all extensions of the package on a Msgpack instance.
+func Option() msgpack.Option {
+	return msgpack.WithExtensions(Extensions()...)
+}
+
+// Extensions returns the extensions of the package.
+func Extensions() []msgpack.Extension {
+	return []msgpack.Extension{
+		{
+			Type:   reflect.TypeOf(time.Duration(0)),
+			Code:   DurationCode,
+			Encode: encodeDuration,
+			Decode: decodeDuration,
+		},
+		{
+			Type:   reflect.TypeOf(net.IP(nil)),
+			Code:   IPCode,
+			Encode: encodeIP,
+			Decode: decodeIP,
+		},
+		{
+			Type:   reflect.TypeOf(netip.Addr{}),
+			Code:   AddrCode,
+			Encode: encodeAddr,
+			Decode: decodeAddr,
+		},
+		{
+			Type:   reflect.TypeOf(netip.Prefix{}),
+			Code:   PrefixCode,
+			Encode: encodePrefix,
+			Decode: decodePrefix,
+		},
+		{
+			Type:   reflect.TypeOf((*url.URL)(nil)),
+			Code:   URLCode,
+			Encode: encodeURL,
+			Decode: decodeURL,
+		},
+		{
+			Type:   reflect.TypeOf(complex64(0)),
+			Code:   Complex64Code,
+			Encode: encodeComplex64,
+			Decode: decodeComplex64,
+		},
+		{
+			Type:   reflect.TypeOf(complex128(0)),
+			Code:   Complex128Code,
+			Encode: encodeComplex128,
+			Decode: decodeComplex128,
+		},
+	}
+}
+
+func encodeDuration(v interface{}) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, uint64(v.(time.Duration))), nil
+}
+
+func decodeDuration(data []byte) (interface{}, error) {
+	if len(data) != 8 {
+		return nil, fmt.Errorf("invalid length %d", len(data))
+	}
+	return time.Duration(binary.BigEndian.Uint64(data)), nil
+}
+
+func encodeIP(v interface{}) ([]byte, error) {
+	ip := v.(net.IP)
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, nil
+	}
+	if len(ip) != net.IPv6len {
+		return nil, fmt.Errorf("invalid IP length %d", len(ip))
+	}
+	return ip, nil
+}
+
+func decodeIP(data []byte) (interface{}, error) {
+	if len(data) != net.IPv4len && len(data) != net.IPv6len {
+		return nil, fmt.Errorf("invalid length %d", len(data))
+	}
+	return net.IP(append([]byte(nil), data...)), nil
+}
+
+func encodeAddr(v interface{}) ([]byte, error) {
+	return v.(netip.Addr).MarshalBinary()
+}
+
+func decodeAddr(data []byte) (interface{}, error) {
+	var addr netip.Addr
+	if err := addr.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	return addr, nil
+}
+
+func encodePrefix(v interface{}) ([]byte, error) {
+	return v.(netip.Prefix).MarshalBinary()
+}
+
+func decodePrefix(data []byte) (interface{}, error) {
+	var prefix netip.Prefix
+	if err := prefix.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	// UnmarshalBinary accepts prefix lengths that do not fit the address
+	if prefix.Addr().IsValid() && !prefix.IsValid() {
+		return nil, fmt.Errorf("invalid prefix length %d for %s", data[len(data)-1], prefix.Addr())
+	}
+	return prefix, nil
+}
+
+func encodeURL(v interface{}) ([]byte, error) {
+	return []byte(v.(*url.URL).String()), nil
+}
+
+func decodeURL(data []byte) (interface{}, error) {
+	return url.Parse(string(data))
+}
+
+func encodeComplex64(v interface{}) ([]byte, error) {
+	c := v.(complex64)
+	data := binary.BigEndian.AppendUint32(nil, math.Float32bits(real(c)))
+	return binary.BigEndian.AppendUint32(data, math.Float32bits(imag(c))), nil
+}
+
+func decodeComplex64(data []byte) (interface{}, error) {
+	if len(data) != 8 {
+		return nil, fmt.Errorf("invalid length %d", len(data))
+	}
+	re := math.Float32frombits(binary.BigEndian.Uint32(data))
+	im := math.Float32frombits(binary.BigEndian.Uint32(data[4:]))
+	return complex(re, im), nil
+}
+
+func encodeComplex128(v interface{}) ([]byte, error) {
+	c := v.(complex128)
+	data := binary.BigEndian.AppendUint64(nil, math.Float64bits(real(c)))
+	return binary.BigEndian.AppendUint64(data, math.Float64bits(imag(c))), nil
+}
+
+func decodeComplex128(data []byte) (interface{}, error) {
+	if len(data) != 16 {
+		return nil, fmt.Errorf("invalid length %d", len(data))
+	}
+	re := math.Float64frombits(binary.BigEndian.Uint64(data))
+	im := math.Float64frombits(binary.BigEndian.Uint64(data[8:]))
+	return complex(re, im), nil
+}
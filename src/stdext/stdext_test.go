@@ -0,0 +1,155 @@
+package stdext
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	msgpack "msgpack/src"
+)
+
+func TestEncoding(t *testing.T) {
+	mp := msgpack.NewMsgpack(Option())
+
+	testCases := []struct {
+		desc     string
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - time.Duration",
+			input:    1500 * time.Millisecond,
+			expected: []byte{0xd7, 0x10, 0x00, 0x00, 0x00, 0x00, 0x59, 0x68, 0x2f, 0x00},
+		},
+		{
+			desc:     "Test Case - IPv4 net.IP",
+			input:    net.ParseIP("192.168.0.1"),
+			expected: []byte{0xd6, 0x11, 0xc0, 0xa8, 0x00, 0x01},
+		},
+		{
+			desc:     "Test Case - netip.Addr",
+			input:    netip.MustParseAddr("10.0.0.1"),
+			expected: []byte{0xd6, 0x12, 0x0a, 0x00, 0x00, 0x01},
+		},
+		{
+			desc:     "Test Case - netip.Prefix",
+			input:    netip.MustParsePrefix("10.0.0.0/8"),
+			expected: []byte{0xc7, 0x05, 0x13, 0x0a, 0x00, 0x00, 0x00, 0x08},
+		},
+		{
+			desc:     "Test Case - *url.URL",
+			input:    &url.URL{Scheme: "http", Host: "a"},
+			expected: []byte{0xd7, 0x14, 0x68, 0x74, 0x74, 0x70, 0x3a, 0x2f, 0x2f, 0x61},
+		},
+		{
+			desc:     "Test Case - nil *url.URL",
+			input:    (*url.URL)(nil),
+			expected: []byte{0xc0},
+		},
+		{
+			desc:     "Test Case - complex64",
+			input:    complex64(complex(1, -1)),
+			expected: []byte{0xd7, 0x15, 0x3f, 0x80, 0x00, 0x00, 0xbf, 0x80, 0x00, 0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+type record struct {
+	Timeout time.Duration `msgpack:"timeout"`
+	IP      net.IP        `msgpack:"ip"`
+	Addr    netip.Addr    `msgpack:"addr"`
+	Prefix  netip.Prefix  `msgpack:"prefix"`
+	URL     *url.URL      `msgpack:"url"`
+	NoURL   *url.URL      `msgpack:"no_url"`
+	C64     complex64     `msgpack:"c64"`
+	C128    complex128    `msgpack:"c128"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	mp := msgpack.NewMsgpack(Option())
+
+	input := record{
+		Timeout: 3 * time.Second,
+		IP:      net.ParseIP("2001:db8::1"),
+		Addr:    netip.MustParseAddr("fe80::1%eth0"),
+		Prefix:  netip.MustParsePrefix("2001:db8::/32"),
+		URL:     &url.URL{Scheme: "https", Host: "example.com", Path: "/a b", RawQuery: "q=1"},
+		C64:     complex(1.5, 2),
+		C128:    complex(-0.25, 1e300),
+	}
+
+	encoded, err := mp.AppendMarshal(nil, input)
+	require.NoError(t, err)
+
+	var result record
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, input, result)
+
+	// interface{} values get the same Go types back
+	generic, err := mp.Unmarshal(encoded)
+	require.NoError(t, err)
+	require.Equal(t, input.Timeout, generic["timeout"])
+	require.Equal(t, input.IP, generic["ip"])
+	require.Equal(t, input.Addr, generic["addr"])
+	require.Equal(t, input.Prefix, generic["prefix"])
+	require.Equal(t, input.URL, generic["url"])
+	require.Nil(t, generic["no_url"])
+	require.Equal(t, input.C64, generic["c64"])
+	require.Equal(t, input.C128, generic["c128"])
+}
+
+func TestDecodeErrors(t *testing.T) {
+	mp := msgpack.NewMsgpack(Option())
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+	}{
+		{
+			desc:       "Test Case - short time.Duration",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xd4, 0x10, 0x00},
+		},
+		{
+			desc:       "Test Case - bad net.IP length",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xd4, 0x11, 0x00},
+		},
+		{
+			desc:       "Test Case - bad netip.Prefix",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xc7, 0x05, 0x13, 0x0a, 0x00, 0x00, 0x00, 0x21},
+		},
+		{
+			desc:       "Test Case - bad *url.URL",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xd4, 0x14, 0x25},
+		},
+		{
+			desc:       "Test Case - short complex128",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0xd7, 0x16, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			_, err := mp.Unmarshal(tc.inputBytes)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNotRegistered(t *testing.T) {
+	// without the option a time.Duration is a plain integer
+	result, err := msgpack.NewMsgpack().AppendMarshal(nil, time.Duration(5))
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x05}, result)
+}
@@ -0,0 +1,221 @@
+package msgpack
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	MsgPackTypes "msgpack/src/types"
+)
+
+// ErrTrailingData is returned when bytes follow the value decoded by Unmarshal or UnmarshalTo.
+var ErrTrailingData = errors.New("trailing data after MessagePack value")
+
+// checkTrailingData reports the bytes left in r unless the options of r allow them.
+func (r *Reader) checkTrailingData() error {
+	if r.opts.AllowTrailingData || r.offset >= len(r.data) {
+		return nil
+	}
+	return fmt.Errorf("%w: %d bytes at offset %d", ErrTrailingData, len(r.data)-r.offset, r.offset)
+}
+
+// DecodeNext decodes the first value of data into its generic Go form
+// and returns the number of bytes it takes, the rest of data is not read.
+func (m *Msgpack) DecodeNext(data []byte) (v interface{}, n int, err error) {
+	r := Reader{data: data, opts: m.decodeOpts}
+
+	v, err = m.decodeMsgpack(&r)
+	if err != nil {
+		return nil, 0, err
+	}
+	return v, r.Offset(), nil
+}
+
+// DecodeAll decodes the back-to-back values of data into their generic Go forms.
+// The decode limits apply to each value on its own.
+func (m *Msgpack) DecodeAll(data []byte) ([]interface{}, error) {
+	var values []interface{}
+
+	it := m.Iterate(data)
+	for it.Next() {
+		values = append(values, it.Value())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Iterator decodes the back-to-back values of a buffer one at a time.
+//
+//	it := mp.Iterate(data)
+//	for it.Next() {
+//		use(it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type Iterator struct {
+	m     *Msgpack
+	r     Reader
+	value interface{}
+	err   error
+}
+
+// Iterate returns an Iterator over the values of data.
+func (m *Msgpack) Iterate(data []byte) *Iterator {
+	return &Iterator{m: m, r: Reader{data: data, opts: m.decodeOpts}}
+}
+
+// Next decodes the next value and reports whether there is one.
+// It returns false at the end of the data or on the first error.
+func (it *Iterator) Next() bool {
+	if it.err != nil || it.r.offset >= len(it.r.data) {
+		it.value = nil
+		return false
+	}
+
+	// the limits apply to each value on its own
+	it.r.allocated = 0
+
+	offset := it.r.offset
+	it.value, it.err = it.m.decodeMsgpack(&it.r)
+	if it.err != nil {
+		it.value = nil
+		it.r.offset = offset
+		return false
+	}
+	return true
+}
+
+// Value returns the value decoded by the last call to Next.
+func (it *Iterator) Value() interface{} {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, or nil at the end of the data.
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+// Offset returns the position of the next value in the data,
+// after an error the position of the value that could not be decoded.
+func (it *Iterator) Offset() int {
+	return it.r.offset
+}
+
+// ReadRaw reads the next complete value from br without decoding it, for streams of back-to-back values
+// without framing. It returns io.EOF when br ends before the value and io.ErrUnexpectedEOF when it ends within it.
+// A value longer than maxSize bytes is an error wrapping ErrFrameTooLarge, DefaultMaxFrameSize applies when maxSize is zero.
+func ReadRaw(br *bufio.Reader, maxSize int) (RawMessage, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+
+	var raw []byte
+	var err error
+
+	// number of values left to read, containers add their elements to it
+	for n := 1; n > 0; n-- {
+		if raw, err = readStreamBytes(br, raw, 1, maxSize); err != nil {
+			if err == io.ErrUnexpectedEOF && len(raw) == 0 {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		code := raw[len(raw)-1]
+
+		// size of the fixed part that follows the type code
+		size := 0
+		switch {
+		case MsgPackTypes.IsMsgPackTypePositiveInt(code), MsgPackTypes.IsMsgPackTypeNegativeInt(code):
+		case MsgPackTypes.IsMsgPackTypeString(code):
+			size = int(code & 0x1F)
+		case MsgPackTypes.IsMsgPackTypeArray(code):
+			n += int(code & 0x0F)
+		case MsgPackTypes.IsMsgPackTypeMap(code):
+			n += 2 * int(code&0x0F)
+		default:
+			switch code {
+			case MsgPackTypes.Nil, MsgPackTypes.False, MsgPackTypes.True:
+			case MsgPackTypes.Uint8, MsgPackTypes.Uint16, MsgPackTypes.Uint32, MsgPackTypes.Uint64:
+				size = 1 << (code - MsgPackTypes.Uint8)
+			case MsgPackTypes.Int8, MsgPackTypes.Int16, MsgPackTypes.Int32, MsgPackTypes.Int64:
+				size = 1 << (code - MsgPackTypes.Int8)
+			case MsgPackTypes.Float32:
+				size = 4
+			case MsgPackTypes.Float64:
+				size = 8
+			case MsgPackTypes.FixExt1, MsgPackTypes.FixExt2, MsgPackTypes.FixExt4, MsgPackTypes.FixExt8, MsgPackTypes.FixExt16:
+				size = 1 + 1<<(code-MsgPackTypes.FixExt1)
+			case MsgPackTypes.Str8, MsgPackTypes.Str16, MsgPackTypes.Str32:
+				size, raw, err = readStreamLength(br, raw, 1<<(code-MsgPackTypes.Str8), maxSize)
+			case MsgPackTypes.Bin8, MsgPackTypes.Bin16, MsgPackTypes.Bin32:
+				size, raw, err = readStreamLength(br, raw, 1<<(code-MsgPackTypes.Bin8), maxSize)
+			case MsgPackTypes.Ext8, MsgPackTypes.Ext16, MsgPackTypes.Ext32:
+				size, raw, err = readStreamLength(br, raw, 1<<(code-MsgPackTypes.Ext8), maxSize)
+				size++ // ext type
+			case MsgPackTypes.Array16, MsgPackTypes.Array32:
+				var length int
+				length, raw, err = readStreamLength(br, raw, 2<<(code-MsgPackTypes.Array16), maxSize)
+				n += length
+			case MsgPackTypes.Map16, MsgPackTypes.Map32:
+				var length int
+				length, raw, err = readStreamLength(br, raw, 2<<(code-MsgPackTypes.Map16), maxSize)
+				n += 2 * length
+			default:
+				return nil, fmt.Errorf("unknown type 0x%02x at offset %d", code, len(raw)-1)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if raw, err = readStreamBytes(br, raw, size, maxSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return raw, nil
+}
+
+// readStreamLength reads a big-endian unsigned length of size bytes from br and appends it to raw.
+func readStreamLength(br *bufio.Reader, raw []byte, size, maxSize int) (int, []byte, error) {
+	raw, err := readStreamBytes(br, raw, size, maxSize)
+	if err != nil {
+		return 0, raw, err
+	}
+
+	var length uint64
+	switch b := raw[len(raw)-size:]; size {
+	case 1:
+		length = uint64(b[0])
+	case 2:
+		length = uint64(binary.BigEndian.Uint16(b))
+	case 4:
+		length = uint64(binary.BigEndian.Uint32(b))
+	}
+	if length > uint64(maxSize) {
+		return 0, raw, fmt.Errorf("%w: value exceeds %d bytes", ErrFrameTooLarge, maxSize)
+	}
+	return int(length), raw, nil
+}
+
+// readStreamBytes appends the next n bytes of br to raw.
+func readStreamBytes(br *bufio.Reader, raw []byte, n, maxSize int) ([]byte, error) {
+	if len(raw)+n > maxSize {
+		return raw, fmt.Errorf("%w: value exceeds %d bytes", ErrFrameTooLarge, maxSize)
+	}
+
+	start := len(raw)
+	raw = append(raw, make([]byte, n)...)
+	if _, err := io.ReadFull(br, raw[start:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return raw[:start], err
+	}
+	return raw, nil
+}
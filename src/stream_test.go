@@ -0,0 +1,210 @@
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrailingData(t *testing.T) {
+	// {"a": 1} followed by a second message
+	data := []byte{0x81, 0xa1, 0x61, 0x01, 0x81, 0xa1, 0x62, 0x02}
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		opts       []Option
+		err        bool
+	}{
+		{
+			desc:       "Test Case - single message",
+			inputBytes: data[:4],
+		},
+		{
+			desc:       "Test Case - concatenated messages",
+			inputBytes: data,
+			err:        true,
+		},
+		{
+			desc:       "Test Case - trailing garbage",
+			inputBytes: append(data[:4:4], 0xc1),
+			err:        true,
+		},
+		{
+			desc:       "Test Case - trailing data allowed",
+			inputBytes: data,
+			opts:       []Option{WithAllowTrailingData()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := NewMsgpack(tc.opts...)
+
+			result, err := mp.Unmarshal(tc.inputBytes)
+			var dst struct {
+				A int `msgpack:"a"`
+			}
+			errTo := mp.UnmarshalTo(tc.inputBytes, &dst)
+
+			if tc.err {
+				require.True(t, errors.Is(err, ErrTrailingData), "got %v", err)
+				require.True(t, errors.Is(errTo, ErrTrailingData), "got %v", errTo)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, errTo)
+			require.Equal(t, map[string]interface{}{"a": 1}, result)
+			require.Equal(t, 1, dst.A)
+		})
+	}
+}
+
+func TestDecodeNext(t *testing.T) {
+	mp := NewMsgpack()
+	data := []byte{0x92, 0x01, 0xa1, 0x61, 0xc3, 0xc1}
+
+	v, n, err := mp.DecodeNext(data)
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{1, "a"}, v)
+	require.Equal(t, 4, n)
+
+	v, n, err = mp.DecodeNext(data[n:])
+	require.NoError(t, err)
+	require.Equal(t, true, v)
+	require.Equal(t, 1, n)
+
+	_, _, err = mp.DecodeNext(data[5:])
+	require.Error(t, err)
+
+	_, _, err = mp.DecodeNext(nil)
+	require.Error(t, err)
+}
+
+func TestDecodeAll(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		expected   []interface{}
+		err        bool
+	}{
+		{
+			desc:       "Test Case - empty",
+			inputBytes: []byte{},
+		},
+		{
+			desc:       "Test Case - back-to-back messages",
+			inputBytes: []byte{0x81, 0xa1, 0x61, 0x01, 0xc0, 0x92, 0x02, 0x03},
+			expected:   []interface{}{map[string]interface{}{"a": 1}, nil, []interface{}{2, 3}},
+		},
+		{
+			desc:       "Test Case - truncated last message",
+			inputBytes: []byte{0x01, 0x92, 0x02},
+			err:        true,
+		},
+		{
+			desc:       "Test Case - invalid byte",
+			inputBytes: []byte{0x01, 0xc1},
+			err:        true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result, err := mp.DecodeAll(tc.inputBytes)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestIterator(t *testing.T) {
+	mp := NewMsgpack(WithLimits(DecodeOptions{MaxAllocBytes: 64}))
+
+	// each array stays below the allocation limit on its own
+	data := []byte{0x92, 0x01, 0x02, 0x92, 0x03, 0x04, 0x92, 0x05, 0x06, 0x92}
+
+	it := mp.Iterate(data)
+	var values []interface{}
+	for it.Next() {
+		values = append(values, it.Value())
+	}
+	require.Equal(t, []interface{}{[]interface{}{1, 2}, []interface{}{3, 4}, []interface{}{5, 6}}, values)
+
+	// the truncated array stops the iteration where it starts
+	require.Error(t, it.Err())
+	require.Equal(t, 9, it.Offset())
+	require.Nil(t, it.Value())
+	require.False(t, it.Next())
+}
+
+func TestReadRaw(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		maxSize    int
+		expected   []byte
+		err        error
+	}{
+		{
+			desc:       "Test Case - nested containers",
+			inputBytes: []byte{0x82, 0xa1, 0x61, 0x92, 0x01, 0xcd, 0x01, 0x00, 0xa1, 0x62, 0xc4, 0x02, 0x00, 0x01, 0xc0},
+			expected:   []byte{0x82, 0xa1, 0x61, 0x92, 0x01, 0xcd, 0x01, 0x00, 0xa1, 0x62, 0xc4, 0x02, 0x00, 0x01},
+		},
+		{
+			desc:       "Test Case - ext and 16-bit array",
+			inputBytes: []byte{0xdc, 0x00, 0x02, 0xd4, 0x01, 0x00, 0xc7, 0x01, 0x02, 0x03},
+			expected:   []byte{0xdc, 0x00, 0x02, 0xd4, 0x01, 0x00, 0xc7, 0x01, 0x02, 0x03},
+		},
+		{
+			desc:       "Test Case - empty stream",
+			inputBytes: []byte{},
+			err:        io.EOF,
+		},
+		{
+			desc:       "Test Case - truncated array",
+			inputBytes: []byte{0x92, 0x01},
+			err:        io.ErrUnexpectedEOF,
+		},
+		{
+			desc:       "Test Case - truncated str",
+			inputBytes: []byte{0xd9, 0x05, 0x61},
+			err:        io.ErrUnexpectedEOF,
+		},
+		{
+			desc:       "Test Case - too large",
+			inputBytes: []byte{0xc6, 0xff, 0xff, 0xff, 0xff},
+			maxSize:    1024,
+			err:        ErrFrameTooLarge,
+		},
+		{
+			desc:       "Test Case - unknown type",
+			inputBytes: []byte{0x91, 0xc1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			raw, err := ReadRaw(bufio.NewReader(bytes.NewReader(tc.inputBytes)), tc.maxSize)
+			if tc.expected == nil {
+				require.Error(t, err)
+				if tc.err != nil {
+					require.True(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+				}
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, RawMessage(tc.expected), raw)
+		})
+	}
+}
@@ -0,0 +1,543 @@
+package msgpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// field describes an exported struct field as it is written to a map.
+type field struct {
+	name      string
+	key       []byte // name encoded as a str value
+	validName bool   // name is valid UTF-8, so key holds for every UTF-8 policy
+	index     []int  // path to the field through embedded and inlined structs
+	omitEmpty bool
+	encoding  fieldEncoding
+
+	// used while compiling only
+	tagged bool
+}
+
+// structPlan is what the encoder and decoder need to know about a struct type,
+// it is compiled once per type and instance and cached in Msgpack.plans.
+type structPlan struct {
+	fields  []field
+	byName  map[string]int // index into fields by exact name
+	inline  []int          // path to the ",inline" map that catches unknown keys, nil without one
+	asArray bool           // the struct is written as an array, see compileStructPlan
+}
+
+var (
+	jsonNumberType = reflect.TypeOf(json.Number(""))
+	extType        = reflect.TypeOf(Ext{})
+)
+
+// structPlanFor returns the cached plan of the struct type t, compiling it on first use.
+func (m *Msgpack) structPlanFor(t reflect.Type) *structPlan {
+	if p, ok := m.plans.Load(t); ok {
+		return p.(*structPlan)
+	}
+	p, _ := m.plans.LoadOrStore(t, m.compileStructPlan(t))
+	return p.(*structPlan)
+}
+
+// compileStructPlan builds the plan of the struct type t. Fields are named by their tag
+// or by the Go field name when the tag has no name. Fields tagged "-" and unexported fields are left out.
+// The fields of embedded structs without a tag name and of structs tagged ",inline" are promoted
+// following the rules of encoding/json: of several fields with the same name the least nested one wins,
+// then the tagged one, and if that leaves more than one all of them are left out.
+//
+// A blank field tagged ",asarray", such as
+//
+//	_ struct{} `msgpack:",asarray"`
+//
+// writes the struct as an array of its field values in declaration order instead of a map.
+func (m *Msgpack) compileStructPlan(t reflect.Type) *structPlan {
+	p := &structPlan{}
+
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.Name == "_" && hasFlag(sf.Tag.Get(m.tagName), "asarray") {
+			p.asArray = true
+		}
+	}
+
+	var candidates []field
+	m.collectFields(t, nil, map[reflect.Type]bool{t: true}, &candidates, &p.inline)
+
+	// group the fields by name
+	byName := make(map[string][]int, len(candidates))
+	for i, f := range candidates {
+		byName[f.name] = append(byName[f.name], i)
+	}
+
+	p.fields = make([]field, 0, len(byName))
+	p.byName = make(map[string]int, len(byName))
+	for i, f := range candidates {
+		if dominantField(candidates, byName[f.name]) == i {
+			p.byName[f.name] = len(p.fields)
+			p.fields = append(p.fields, f)
+		}
+	}
+
+	return p
+}
+
+// dominantField returns the index of the field that wins among the fields at indexes,
+// which share a name, or -1 if none does.
+func dominantField(fields []field, indexes []int) int {
+	if len(indexes) == 1 {
+		return indexes[0]
+	}
+
+	// the least nested fields
+	depth := len(fields[indexes[0]].index)
+	for _, i := range indexes[1:] {
+		if len(fields[i].index) < depth {
+			depth = len(fields[i].index)
+		}
+	}
+
+	found, tagged := -1, -1
+	count, taggedCount := 0, 0
+	for _, i := range indexes {
+		if len(fields[i].index) != depth {
+			continue
+		}
+		found = i
+		count++
+		if fields[i].tagged {
+			tagged = i
+			taggedCount++
+		}
+	}
+
+	switch {
+	case taggedCount == 1:
+		return tagged
+	case taggedCount == 0 && count == 1:
+		return found
+	}
+	return -1
+}
+
+// collectFields appends the fields of the struct type t found at index to fields in declaration order,
+// descending into embedded and inlined structs that are not in visited.
+func (m *Msgpack) collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool, fields *[]field, inline *[]int) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		tag := sf.Tag.Get(m.tagName)
+		if tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// exported fields of embedded structs are promoted even if the struct type is not exported,
+		// unless it is embedded by pointer and could not be allocated
+		if sf.Anonymous {
+			if !sf.IsExported() && sf.Type.Kind() != reflect.Struct {
+				continue
+			}
+		} else if !sf.IsExported() {
+			continue
+		}
+
+		f := field{
+			name:   name,
+			index:  append(index[:len(index):len(index)], i),
+			tagged: name != "",
+		}
+		inlined := false
+		for flags != "" {
+			var flag string
+			flag, flags, _ = strings.Cut(flags, ",")
+			switch flag {
+			case "omitempty":
+				f.omitEmpty = true
+			case "inline":
+				inlined = true
+			}
+		}
+
+		// flatten embedded and inlined structs
+		if ft.Kind() == reflect.Struct && ft != extType && ft != bigIntType && ft != bigFloatType && ft != timeType &&
+			m.extensionFor(sf.Type) == nil && (sf.Anonymous && name == "" || inlined) {
+			if !visited[ft] {
+				visited[ft] = true
+				m.collectFields(ft, f.index, visited, fields, inline)
+				delete(visited, ft)
+			}
+			continue
+		}
+
+		// an inlined map catches the keys of no other field, the least nested one wins
+		if inlined && sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String {
+			if *inline == nil || len(f.index) < len(*inline) {
+				*inline = f.index
+			}
+			continue
+		}
+
+		if f.name == "" {
+			f.name = sf.Name
+		}
+		f.key = AppendString(nil, f.name)
+		f.validName = utf8.ValidString(f.name)
+		f.encoding = m.fieldEncodingFor(sf.Type)
+		*fields = append(*fields, f)
+	}
+}
+
+// hasFlag reports whether the struct tag tag has the flag after its name.
+func hasFlag(tag, flag string) bool {
+	_, flags, _ := strings.Cut(tag, ",")
+	for flags != "" {
+		var f string
+		f, flags, _ = strings.Cut(flags, ",")
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldByIndex returns the field of the struct v at index,
+// ok is false when a nil embedded pointer is in the way.
+func fieldByIndex(v reflect.Value, index []int) (fv reflect.Value, ok bool) {
+	for j, i := range index {
+		if j > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc is like fieldByIndex but allocates nil embedded pointers in the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for j, i := range index {
+		if j > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// fieldEncoding selects how encodeField writes the values of a field.
+type fieldEncoding uint8
+
+const (
+	encodeGeneric fieldEncoding = iota // through handleValue
+	encodeBoolField
+	encodeIntField
+	encodeUintField
+	encodeFloat32Field
+	encodeFloat64Field
+	encodeStringField
+	encodeStructField
+	encodeInterfaceField
+)
+
+// fieldEncodingFor returns the encoding that writes values of type t without boxing them
+// when handleValue has no special case or extension for t.
+func (m *Msgpack) fieldEncodingFor(t reflect.Type) fieldEncoding {
+	if m.extensionFor(t) != nil {
+		return encodeGeneric
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return encodeBoolField
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return encodeIntField
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return encodeUintField
+	case reflect.Float32:
+		return encodeFloat32Field
+	case reflect.Float64:
+		return encodeFloat64Field
+	case reflect.String:
+		// json.Number is written as a number
+		if t != jsonNumberType {
+			return encodeStringField
+		}
+	case reflect.Struct:
+		// Ext, big numbers and times are written as ext values
+		if t != extType && t != bigIntType && t != bigFloatType && t != timeType {
+			return encodeStructField
+		}
+	case reflect.Interface:
+		return encodeInterfaceField
+	}
+	return encodeGeneric
+}
+
+// encodeField writes the value v of f.
+func (m *Msgpack) encodeField(result *[]byte, f *field, v reflect.Value, opts *encodeState) error {
+	switch f.encoding {
+	case encodeBoolField:
+		*result = AppendBool(*result, v.Bool())
+	case encodeIntField:
+		*result = AppendInt(*result, v.Int())
+	case encodeUintField:
+		*result = AppendUint(*result, v.Uint())
+	case encodeFloat32Field:
+		*result = AppendFloat32(*result, float32(v.Float()))
+	case encodeFloat64Field:
+		encodeFloat64(result, v.Float(), opts)
+	case encodeStringField:
+		return m.encodeString(result, v.String(), opts)
+	case encodeStructField:
+		return m.encodeStruct(result, v, opts)
+	case encodeInterfaceField:
+		return m.encodeInterface(result, v.Interface(), opts)
+	default:
+		return m.handleValue(result, v.Interface(), opts)
+	}
+	return nil
+}
+
+// encodeStruct writes the struct v as a map of its fields in declaration order,
+// followed by the keys of its inlined map that no field uses.
+func (m *Msgpack) encodeStruct(result *[]byte, v reflect.Value, opts *encodeState) error {
+	p := m.structPlanFor(v.Type())
+	if p.asArray || opts.StructAsArray {
+		return m.encodeStructArray(result, p, v, opts)
+	}
+
+	// count the fields that are written
+	n := 0
+	for i := range p.fields {
+		if fv, ok := fieldByIndex(v, p.fields[i].index); ok && !omitField(&p.fields[i], fv, opts) {
+			n++
+		}
+	}
+
+	var inline reflect.Value
+	var keys []reflect.Value
+	if p.inline != nil {
+		if inline, _ = fieldByIndex(v, p.inline); inline.IsValid() && inline.Len() > 0 {
+			keys = inline.MapKeys()
+			for _, key := range keys {
+				if _, ok := p.byName[key.String()]; !ok {
+					n++
+				}
+			}
+		}
+	}
+
+	// add type prefix
+	*result = AppendMapHeader(*result, n)
+
+	// add value
+	for i := range p.fields {
+		f := &p.fields[i]
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || omitField(f, fv, opts) {
+			continue
+		}
+
+		// add key, encoded once unless the options change its encoding
+		if opts.LegacyRaw || !f.validName && opts.InvalidUTF8 != UTF8Allow {
+			if err := m.encodeMapKey(result, f.name, opts); err != nil {
+				return err
+			}
+		} else {
+			*result = append(*result, f.key...)
+		}
+
+		if err := m.encodeField(result, f, fv, opts); err != nil {
+			return err
+		}
+	}
+
+	if opts.SortMapKeys {
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+	}
+
+	// add the inlined keys
+	if len(keys) > 0 {
+		if err := opts.enter(inline); err != nil {
+			return err
+		}
+		defer opts.leave(inline)
+	}
+	for _, key := range keys {
+		if _, ok := p.byName[key.String()]; ok {
+			continue
+		}
+		if err := m.encodeMapKey(result, key.String(), opts); err != nil {
+			return err
+		}
+		if err := m.encodeElem(result, inline.MapIndex(key), opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeStructArray writes the struct v as an array of its field values in declaration order.
+// Positions matter, so no field is omitted and the fields behind a nil embedded pointer are written as nil.
+func (m *Msgpack) encodeStructArray(result *[]byte, p *structPlan, v reflect.Value, opts *encodeState) error {
+	// add type prefix
+	*result = AppendArrayHeader(*result, len(p.fields))
+
+	// add value
+	for i := range p.fields {
+		fv, ok := fieldByIndex(v, p.fields[i].index)
+		if !ok {
+			*result = AppendNil(*result)
+			continue
+		}
+		if err := m.encodeField(result, &p.fields[i], fv, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// omitField reports whether the value v of f is left out of the encoded struct.
+func omitField(f *field, v reflect.Value, opts *encodeState) bool {
+	return (f.omitEmpty || opts.OmitEmpty) && isEmptyValue(v)
+}
+
+// isEmptyValue reports whether v is false, 0, a nil pointer or interface, or has no elements.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// decodeStructValue decodes mapLen key-value pairs into the fields of the struct rv.
+// Keys match field names exactly or, failing that, case-insensitively.
+func (m *Msgpack) decodeStructValue(r *Reader, rv reflect.Value, mapLen int) error {
+	p := m.structPlanFor(rv.Type())
+
+	for j := 0; j < mapLen; j++ {
+		offset := r.Offset()
+
+		// parse map key
+		tok, err := r.Next()
+		if err == io.EOF {
+			return fmt.Errorf("data out of range")
+		}
+		if err != nil {
+			return err
+		}
+		if tok.Kind != StrKind {
+			return fmt.Errorf("expected string map key at offset %d, got %s", offset, tok.Kind)
+		}
+
+		// the lookup of an exact match does not allocate the key
+		i, ok := p.byName[string(tok.Bytes)]
+		if !ok {
+			key, err := decodeMapKey(tok.Bytes, r.opts.InvalidUTF8, offset)
+			if err != nil {
+				return err
+			}
+
+			i, ok = p.foldedField(key)
+			if !ok {
+				if p.inline != nil {
+					if err := m.decodeInlineValue(r, fieldByIndexAlloc(rv, p.inline), key); err != nil {
+						return err
+					}
+					continue
+				}
+				if r.opts.DisallowUnknownFields {
+					return fmt.Errorf("unknown field %q at offset %d for Go value of type %s", key, offset, rv.Type())
+				}
+				if err := r.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		// parse map value
+		if err := m.decodeValue(r, fieldByIndexAlloc(rv, p.fields[i].index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeInlineValue decodes the value of an unknown key into the inlined map rv.
+func (m *Msgpack) decodeInlineValue(r *Reader, rv reflect.Value, key string) error {
+	t := rv.Type()
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(t))
+	}
+
+	elem := reflect.New(t.Elem()).Elem()
+	if err := m.decodeValue(r, elem); err != nil {
+		return err
+	}
+
+	rv.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+	return nil
+}
+
+// decodeStructArray decodes arrLen values into the fields of the struct rv in declaration order.
+// Fields past the end of a shorter array keep their value, values past the last field are skipped.
+func (m *Msgpack) decodeStructArray(r *Reader, rv reflect.Value, arrLen int) error {
+	p := m.structPlanFor(rv.Type())
+
+	for j := 0; j < arrLen; j++ {
+		if j >= len(p.fields) {
+			if err := r.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := m.decodeValue(r, fieldByIndexAlloc(rv, p.fields[j].index)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// foldedField returns the index of the first field whose name matches key case-insensitively.
+func (p *structPlan) foldedField(key string) (int, bool) {
+	for i := range p.fields {
+		if strings.EqualFold(p.fields[i].name, key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,594 @@
+package msgpack
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type structTestUser struct {
+	Name    string `msgpack:"name"`
+	Age     int    `msgpack:"age,omitempty"`
+	Email   string
+	Ignored string `msgpack:"-"`
+	private string
+}
+
+func TestMarshalStruct(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc:  "Test Case - tagged fields",
+			input: structTestUser{Name: "a", Age: 1, Email: "b", Ignored: "c", private: "d"},
+			expected: []byte{
+				0x83,
+				0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61,
+				0xa3, 0x61, 0x67, 0x65, 0x01,
+				0xa5, 0x45, 0x6d, 0x61, 0x69, 0x6c, 0xa1, 0x62,
+			},
+		},
+		{
+			desc:  "Test Case - omitempty",
+			input: structTestUser{Name: "a"},
+			expected: []byte{
+				0x82,
+				0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61,
+				0xa5, 0x45, 0x6d, 0x61, 0x69, 0x6c, 0xa0,
+			},
+		},
+		{
+			desc:  "Test Case - nested struct",
+			input: map[string]interface{}{"u": struct{ A []int }{A: []int{1}}},
+			expected: []byte{
+				0x81, 0xa1, 0x75,
+				0x81, 0xa1, 0x41, 0x91, 0x01,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		opts       []Option
+		inputBytes []byte
+		expected   structTestUser
+		wantErr    bool
+	}{
+		{
+			desc: "Test Case - tagged fields",
+			inputBytes: []byte{
+				0x83,
+				0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61,
+				0xa3, 0x61, 0x67, 0x65, 0x01,
+				0xa5, 0x45, 0x6d, 0x61, 0x69, 0x6c, 0xa1, 0x62,
+			},
+			expected: structTestUser{Name: "a", Age: 1, Email: "b"},
+		},
+		{
+			desc: "Test Case - case-insensitive match",
+			inputBytes: []byte{
+				0x81,
+				0xa5, 0x65, 0x6d, 0x61, 0x69, 0x6c, 0xa1, 0x62,
+			},
+			expected: structTestUser{Email: "b"},
+		},
+		{
+			desc: "Test Case - unknown field skipped",
+			inputBytes: []byte{
+				0x82,
+				0xa1, 0x78, 0x92, 0x01, 0x02,
+				0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa1, 0x61,
+			},
+			expected: structTestUser{Name: "a"},
+		},
+		{
+			desc: "Test Case - unknown field disallowed",
+			opts: []Option{WithDisallowUnknownFields()},
+			inputBytes: []byte{
+				0x81,
+				0xa1, 0x78, 0x01,
+			},
+			wantErr: true,
+		},
+		{
+			desc: "Test Case - ignored field",
+			inputBytes: []byte{
+				0x81,
+				0xa7, 0x49, 0x67, 0x6e, 0x6f, 0x72, 0x65, 0x64, 0xa1, 0x63,
+			},
+			expected: structTestUser{},
+		},
+		{
+			desc:       "Test Case - not a map",
+			inputBytes: []byte{0x91, 0x01},
+			wantErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := NewMsgpack(tc.opts...)
+
+			var result structTestUser
+			err := mp.UnmarshalTo(tc.inputBytes, &result)
+			if tc.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	mp := NewMsgpack()
+
+	type inner struct {
+		Tags []string `msgpack:"tags"`
+	}
+	type outer struct {
+		ID    uint64            `msgpack:"id"`
+		Inner inner             `msgpack:"inner"`
+		Ptr   *inner            `msgpack:"ptr"`
+		Meta  map[string]string `msgpack:"meta"`
+	}
+
+	input := outer{ID: 7, Inner: inner{Tags: []string{"a", "b"}}, Meta: map[string]string{"k": "v"}}
+
+	encoded, err := mp.AppendMarshal(nil, input)
+	require.NoError(t, err)
+
+	var result outer
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, input, result)
+}
+
+func TestUnmarshalArray(t *testing.T) {
+	mp := NewMsgpack()
+
+	type withArrays struct {
+		A  [3]int    `msgpack:"a"`
+		ID [4]byte   `msgpack:"id"`
+		S  [2]string `msgpack:"s"`
+	}
+
+	// round trip, byte arrays are written as arrays of ints
+	input := withArrays{A: [3]int{1, 2, 3}, ID: [4]byte{0xde, 0xad, 0xbe, 0xef}, S: [2]string{"x", "y"}}
+	encoded, err := mp.AppendMarshal(nil, input)
+	require.NoError(t, err)
+
+	var result withArrays
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, input, result)
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		target     interface{} // pointer to a filled array, to check that missing elements are zeroed
+		expected   interface{}
+	}{
+		{
+			desc:       "Test Case - longer array is cut",
+			inputBytes: []byte{0x94, 0x01, 0x02, 0x03, 0x92, 0x04, 0x05},
+			target:     &[3]int{9, 9, 9},
+			expected:   &[3]int{1, 2, 3},
+		},
+		{
+			desc:       "Test Case - shorter array zeroes the rest",
+			inputBytes: []byte{0x91, 0x07},
+			target:     &[3]int{9, 9, 9},
+			expected:   &[3]int{7, 0, 0},
+		},
+		{
+			desc:       "Test Case - byte array from bin",
+			inputBytes: []byte{0xc4, 0x04, 0xde, 0xad, 0xbe, 0xef},
+			target:     &[4]byte{9, 9, 9, 9},
+			expected:   &[4]byte{0xde, 0xad, 0xbe, 0xef},
+		},
+		{
+			desc:       "Test Case - shorter bin zeroes the rest",
+			inputBytes: []byte{0xc4, 0x02, 0xde, 0xad},
+			target:     &[4]byte{9, 9, 9, 9},
+			expected:   &[4]byte{0xde, 0xad, 0x00, 0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.NoError(t, mp.UnmarshalTo(tc.inputBytes, tc.target))
+			require.Equal(t, tc.expected, tc.target)
+		})
+	}
+}
+
+func TestStructPlanCache(t *testing.T) {
+	mp := NewMsgpack()
+	typ := reflect.TypeOf(structTestUser{})
+
+	p := mp.structPlanFor(typ)
+	require.Same(t, p, mp.structPlanFor(typ))
+
+	// plans depend on the tag name, so instances do not share them
+	require.NotSame(t, p, NewMsgpack().structPlanFor(typ))
+
+	var wg sync.WaitGroup
+	plans := make([]*structPlan, 8)
+	for i := range plans {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			plans[i] = NewMsgpack().structPlanFor(typ)
+		}(i)
+	}
+	wg.Wait()
+	for _, p := range plans {
+		require.Equal(t, []string{"name", "age", "Email"}, fieldNames(p))
+	}
+}
+
+func fieldNames(p *structPlan) []string {
+	names := make([]string, len(p.fields))
+	for i, f := range p.fields {
+		names[i] = f.name
+	}
+	return names
+}
+
+type benchmarkStruct struct {
+	ID       uint64            `msgpack:"id"`
+	Name     string            `msgpack:"name"`
+	Email    string            `msgpack:"email"`
+	Age      int               `msgpack:"age"`
+	Balance  float64           `msgpack:"balance"`
+	Active   bool              `msgpack:"active"`
+	Tags     []string          `msgpack:"tags"`
+	Address  benchmarkAddress  `msgpack:"address"`
+	Meta     map[string]string `msgpack:"meta,omitempty"`
+	Internal string            `msgpack:"-"`
+}
+
+type benchmarkAddress struct {
+	City string `msgpack:"city"`
+	Zip  string `msgpack:"zip"`
+}
+
+func benchmarkStructValue() benchmarkStruct {
+	return benchmarkStruct{
+		ID:      42,
+		Name:    "John Doe",
+		Email:   "john@doe.com",
+		Age:     35,
+		Balance: -1234.5,
+		Active:  true,
+		Tags:    []string{"a", "b"},
+		Address: benchmarkAddress{City: "Taipei", Zip: "100"},
+	}
+}
+
+// BenchmarkMarshalStructParallel compares the cached plans of one shared instance
+// with a new instance per call, which compiles the plans every time.
+func BenchmarkMarshalStructParallel(b *testing.B) {
+	data := benchmarkStructValue()
+
+	b.Run("cached", func(b *testing.B) {
+		mp := NewMsgpack()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			buf := make([]byte, 0, 1024)
+			for pb.Next() {
+				var err error
+				if buf, err = mp.AppendMarshal(buf[:0], data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			buf := make([]byte, 0, 1024)
+			for pb.Next() {
+				var err error
+				if buf, err = NewMsgpack().AppendMarshal(buf[:0], data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+// BenchmarkUnmarshalStructParallel is the decoding counterpart of BenchmarkMarshalStructParallel.
+func BenchmarkUnmarshalStructParallel(b *testing.B) {
+	data, err := NewMsgpack().AppendMarshal(nil, benchmarkStructValue())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		mp := NewMsgpack()
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var v benchmarkStruct
+				if err := mp.UnmarshalTo(data, &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				var v benchmarkStruct
+				if err := NewMsgpack().UnmarshalTo(data, &v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	})
+}
+
+type structTestAudit struct {
+	CreatedAt int64 `msgpack:"created_at"`
+	UpdatedAt int64 `msgpack:"updated_at"`
+}
+
+// StructTestNamed is exported because the fields behind an embedded pointer to an unexported type are not promoted.
+type StructTestNamed struct {
+	ID   int `msgpack:"id"`
+	Name string
+}
+
+type structTestOther struct {
+	Name  string
+	Extra string
+}
+
+type structTestTagged struct {
+	Extra string `msgpack:"Extra"`
+}
+
+type structTestEmbedding struct {
+	structTestAudit
+	*StructTestNamed
+	structTestOther
+	structTestTagged
+	Inline structTestPoint        `msgpack:",inline"`
+	Rest   map[string]interface{} `msgpack:",inline"`
+}
+
+type structTestPoint struct {
+	X int `msgpack:"x"`
+}
+
+func TestEmbeddedStructFields(t *testing.T) {
+	mp := NewMsgpack()
+
+	p := mp.structPlanFor(reflect.TypeOf(structTestEmbedding{}))
+
+	// Name is cancelled by two untagged fields at the same depth, the tagged Extra wins
+	require.Equal(t, []string{"created_at", "updated_at", "id", "Extra", "x"}, fieldNames(p))
+	require.Equal(t, []int{3, 0}, p.fields[3].index)
+	require.Equal(t, []int{5}, p.inline)
+}
+
+func TestMarshalEmbeddedStruct(t *testing.T) {
+	mp := NewMsgpack(WithSortMapKeys())
+
+	testCases := []struct {
+		desc     string
+		input    structTestEmbedding
+		expected map[string]interface{}
+	}{
+		{
+			desc: "Test Case - nil embedded pointer",
+			input: structTestEmbedding{
+				structTestAudit:  structTestAudit{CreatedAt: 1, UpdatedAt: 2},
+				structTestTagged: structTestTagged{Extra: "e"},
+				Inline:           structTestPoint{X: 3},
+			},
+			expected: map[string]interface{}{
+				"created_at": 1, "updated_at": 2, "Extra": "e", "x": 3,
+			},
+		},
+		{
+			desc: "Test Case - embedded pointer and inlined map",
+			input: structTestEmbedding{
+				StructTestNamed: &StructTestNamed{ID: 4},
+				Rest:            map[string]interface{}{"b": "y", "a": "x", "id": "shadowed"},
+			},
+			expected: map[string]interface{}{
+				"created_at": 0, "updated_at": 0, "id": 4, "Extra": "", "x": 0, "a": "x", "b": "y",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			encoded, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+
+			result, err := mp.Unmarshal(encoded)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestUnmarshalEmbeddedStruct(t *testing.T) {
+	mp := NewMsgpack(WithDisallowUnknownFields())
+
+	encoded, err := mp.AppendMarshal(nil, map[string]interface{}{
+		"created_at": 1,
+		"id":         2,
+		"Name":       "cancelled",
+		"x":          3,
+		"unknown":    []interface{}{"kept"},
+	})
+	require.NoError(t, err)
+
+	var result structTestEmbedding
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, structTestEmbedding{
+		structTestAudit: structTestAudit{CreatedAt: 1},
+		StructTestNamed: &StructTestNamed{ID: 2},
+		Inline:          structTestPoint{X: 3},
+		Rest: map[string]interface{}{
+			"Name":    "cancelled",
+			"unknown": []interface{}{"kept"},
+		},
+	}, result)
+
+	// round trip
+	reencoded, err := mp.AppendMarshal(nil, result)
+	require.NoError(t, err)
+
+	var again structTestEmbedding
+	require.NoError(t, mp.UnmarshalTo(reencoded, &again))
+	require.Equal(t, result, again)
+}
+
+func TestEmbeddedTimeFields(t *testing.T) {
+	mp := NewMsgpack()
+
+	type audit struct {
+		CreatedAt time.Time  `msgpack:"created_at"`
+		UpdatedAt *time.Time `msgpack:"updated_at"`
+	}
+	type record struct {
+		audit
+		Name string `msgpack:"name"`
+	}
+
+	created := time.Date(2024, 5, 6, 7, 8, 9, 123456789, time.UTC)
+	updated := created.Add(time.Hour)
+	input := record{audit: audit{CreatedAt: created, UpdatedAt: &updated}, Name: "a"}
+
+	encoded, err := mp.AppendMarshal(nil, input)
+	require.NoError(t, err)
+
+	generic, err := mp.Unmarshal(encoded)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"created_at": created, "updated_at": updated, "name": "a"}, generic)
+
+	var result record
+	require.NoError(t, mp.UnmarshalTo(encoded, &result))
+	require.Equal(t, input, result)
+}
+
+func TestEmbeddedStructCycle(t *testing.T) {
+	type node struct {
+		Value int
+		*node
+	}
+
+	mp := NewMsgpack()
+	require.Equal(t, []string{"Value"}, fieldNames(mp.structPlanFor(reflect.TypeOf(node{}))))
+}
+
+type structTestPositional struct {
+	_    struct{} `msgpack:",asarray"`
+	ID   int      `msgpack:"id"`
+	Name string   `msgpack:"name,omitempty"`
+	Tags []string `msgpack:"tags"`
+}
+
+func TestMarshalStructAsArray(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		opts     []Option
+		input    interface{}
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - asarray tag",
+			input:    structTestPositional{ID: 1, Tags: []string{"a"}},
+			expected: []byte{0x93, 0x01, 0xa0, 0x91, 0xa1, 0x61},
+		},
+		{
+			desc:     "Test Case - global option",
+			opts:     []Option{WithStructAsArray()},
+			input:    structTestPoint{X: 3},
+			expected: []byte{0x91, 0x03},
+		},
+		{
+			desc: "Test Case - nil embedded pointer",
+			opts: []Option{WithStructAsArray()},
+			input: struct {
+				A int
+				*StructTestNamed
+			}{A: 1},
+			expected: []byte{0x93, 0x01, 0xc0, 0xc0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			mp := NewMsgpack(tc.opts...)
+
+			result, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
+
+func TestUnmarshalStructAsArray(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc       string
+		inputBytes []byte
+		expected   structTestPositional
+	}{
+		{
+			desc:       "Test Case - all fields",
+			inputBytes: []byte{0x93, 0x01, 0xa1, 0x62, 0x91, 0xa1, 0x61},
+			expected:   structTestPositional{ID: 1, Name: "b", Tags: []string{"a"}},
+		},
+		{
+			desc:       "Test Case - shorter array",
+			inputBytes: []byte{0x91, 0x01},
+			expected:   structTestPositional{ID: 1},
+		},
+		{
+			desc:       "Test Case - longer array",
+			inputBytes: []byte{0x95, 0x01, 0xa1, 0x62, 0xc0, 0x81, 0xa1, 0x78, 0x01, 0x92, 0x01, 0x02},
+			expected:   structTestPositional{ID: 1, Name: "b"},
+		},
+		{
+			desc:       "Test Case - map",
+			inputBytes: []byte{0x81, 0xa2, 0x69, 0x64, 0x01},
+			expected:   structTestPositional{ID: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			var result structTestPositional
+			require.NoError(t, mp.UnmarshalTo(tc.inputBytes, &result))
+			require.Equal(t, tc.expected, result)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// TimestampExtType is the ext type of the timestamps of the MessagePack spec,
+// time.Time values are written as and decode from it, in UTC.
+const TimestampExtType int8 = -1
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// appendTime appends t to dst as a TimestampExtType ext value, in the smallest
+// of the timestamp 32, 64 and 96 formats that holds it.
+func appendTime(dst []byte, t time.Time) []byte {
+	sec, nsec := uint64(t.Unix()), uint64(t.Nanosecond())
+
+	var data [12]byte
+	if sec>>34 == 0 {
+		data64 := nsec<<34 | sec
+		if data64&0xffffffff00000000 == 0 {
+			binary.BigEndian.PutUint32(data[:4], uint32(data64))
+			return AppendExt(dst, TimestampExtType, data[:4])
+		}
+		binary.BigEndian.PutUint64(data[:8], data64)
+		return AppendExt(dst, TimestampExtType, data[:8])
+	}
+
+	binary.BigEndian.PutUint32(data[:4], uint32(nsec))
+	binary.BigEndian.PutUint64(data[4:], sec)
+	return AppendExt(dst, TimestampExtType, data[:])
+}
+
+// decodeTime decodes the data of a TimestampExtType ext value.
+func decodeTime(data []byte) (time.Time, error) {
+	var sec, nsec int64
+	switch len(data) {
+	case 4:
+		sec = int64(binary.BigEndian.Uint32(data))
+	case 8:
+		data64 := binary.BigEndian.Uint64(data)
+		sec, nsec = int64(data64&(1<<34-1)), int64(data64>>34)
+	case 12:
+		nsec = int64(binary.BigEndian.Uint32(data))
+		sec = int64(binary.BigEndian.Uint64(data[4:]))
+	default:
+		return time.Time{}, fmt.Errorf("invalid timestamp ext data of %d bytes", len(data))
+	}
+
+	if nsec > 999999999 {
+		return time.Time{}, fmt.Errorf("invalid timestamp nanoseconds %d", nsec)
+	}
+	return time.Unix(sec, nsec).UTC(), nil
+}
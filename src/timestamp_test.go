@@ -0,0 +1,69 @@
+package msgpack
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMarshalTime(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		input    time.Time
+		expected []byte
+	}{
+		{
+			desc:     "Test Case - timestamp 32",
+			input:    time.Unix(1, 0),
+			expected: []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01},
+		},
+		{
+			desc:     "Test Case - timestamp 64",
+			input:    time.Unix(1, 1),
+			expected: []byte{0xd7, 0xff, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x01},
+		},
+		{
+			desc:     "Test Case - timestamp 96 before 1970",
+			input:    time.Unix(-1, 0),
+			expected: []byte{0xc7, 0x0c, 0xff, 0x00, 0x00, 0x00, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		},
+		{
+			desc:     "Test Case - timestamp 96 after 2514",
+			input:    time.Unix(1<<34, 5),
+			expected: []byte{0xc7, 0x0c, 0xff, 0x00, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x00},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			encoded, err := mp.AppendMarshal(nil, tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, encoded)
+
+			var result time.Time
+			require.NoError(t, mp.UnmarshalTo(encoded, &result))
+			require.True(t, tc.input.Equal(result), "got %v", result)
+			require.Equal(t, time.UTC, result.Location())
+		})
+	}
+}
+
+func TestUnmarshalTime(t *testing.T) {
+	mp := NewMsgpack()
+
+	// {"t": timestamp 32 of 1}
+	output, err := mp.Unmarshal([]byte{0x81, 0xa1, 0x74, 0xd6, 0xff, 0x00, 0x00, 0x00, 0x01})
+	require.NoError(t, err)
+	require.Equal(t, time.Unix(1, 0).UTC(), output["t"])
+
+	// nanoseconds above 999999999
+	_, err = mp.Unmarshal([]byte{0x81, 0xa1, 0x74, 0xd7, 0xff, 0xff, 0xff, 0xff, 0xfc, 0x00, 0x00, 0x00, 0x00})
+	require.ErrorContains(t, err, "timestamp")
+
+	// data of no timestamp format
+	_, err = mp.Unmarshal([]byte{0x81, 0xa1, 0x74, 0xd5, 0xff, 0x00, 0x01})
+	require.ErrorContains(t, err, "timestamp")
+}
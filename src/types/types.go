@@ -40,3 +40,10 @@ const (
 	Map32     = 0xdf
 	NegFixInt = 0xff
 )
+
+// Raw type codes of the pre-2013 spec, which the str family took over
+const (
+	FixRaw = FixStr // 0xa0 - 0xbf
+	Raw16  = Str16
+	Raw32  = Str32
+)
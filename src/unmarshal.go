@@ -1,178 +1,172 @@
 package msgpack
 
 import (
-	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
+	"reflect"
+	"strconv"
+
 	MsgPackTypes "msgpack/src/types"
 )
 
+// interfaceType is the type of interface{} map keys, see DecodeOptions.MapType.
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
 // Unmarshal converts data from MessagePack format to JSON format.
+// Bytes after the map are an error wrapping ErrTrailingData unless DecodeOptions.AllowTrailingData is set.
 func (m *Msgpack) Unmarshal(data []byte) (map[string]interface{}, error) {
-	var i int
-	var jsonObj map[string]interface{}
+	return m.UnmarshalWithOptions(data, m.decodeOpts)
+}
+
+// UnmarshalWithOptions is like Unmarshal but decodes according to opts, which replace the decode options of m.
+// It returns a *LimitError when data exceeds the limits of opts.
+func (m *Msgpack) UnmarshalWithOptions(data []byte, opts DecodeOptions) (map[string]interface{}, error) {
+	r := Reader{data: data, opts: opts}
 
-	jsonObjOutput, err := m.decodeMsgpack(data, &jsonObj, &i)
+	jsonObjOutput, err := m.decodeMsgpack(&r)
 
 	if err != nil {
 		return nil, err
 	}
-	return jsonObjOutput.(map[string]interface{}), nil
+	if err := r.checkTrailingData(); err != nil {
+		return nil, err
+	}
+
+	jsonObj, ok := toStringMap(jsonObjOutput)
+	if !ok {
+		return nil, fmt.Errorf("expected map, got %T", jsonObjOutput)
+	}
+	return jsonObj, nil
 }
 
-func (m *Msgpack) decodeMsgpack(data []byte, jsonObj *map[string]interface{}, i *int) (interface{}, error) {
-	currentByte := data[*i]
+// toStringMap returns v as a map[string]interface{}, copying the top level of maps
+// of another type decoded for DecodeOptions.MapType.
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	if jsonObj, ok := v.(map[string]interface{}); ok {
+		return jsonObj, true
+	}
 
-	switch {
-	case MsgPackTypes.IsMsgPackTypeNil(currentByte):
-		*i++
-		return nil, nil
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Map {
+		return nil, false
+	}
 
-	case MsgPackTypes.IsMsgPackTypeTrue(currentByte):
-		*i++
-		return true, nil
+	jsonObj := make(map[string]interface{}, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key := iter.Key()
+		if key.Kind() == reflect.Interface {
+			key = key.Elem()
+		}
+		if key.Kind() != reflect.String {
+			return nil, false
+		}
+		jsonObj[key.String()] = iter.Value().Interface()
+	}
+	return jsonObj, true
+}
 
-	case MsgPackTypes.IsMsgPackTypeFalse(currentByte):
-		*i++
-		return false, nil
+// UnmarshalTo decodes data from MessagePack format into the value pointed to by v.
+// A RawMessage destination keeps the encoded bytes of its value for decoding later.
+// Bytes after the value are an error wrapping ErrTrailingData unless DecodeOptions.AllowTrailingData is set.
+func (m *Msgpack) UnmarshalTo(data []byte, v interface{}) error {
+	return m.UnmarshalToWithOptions(data, v, m.decodeOpts)
+}
 
-	case MsgPackTypes.IsMsgPackTypeString(currentByte):
-		str, err := m.handleMsgPackTypeString(data, jsonObj, i)
-		if err != nil {
-			return nil, err
-		}
-		return str, nil
+// UnmarshalToWithOptions is like UnmarshalTo but decodes according to opts, which replace the decode options of m.
+// It returns a *LimitError when data exceeds the limits of opts.
+func (m *Msgpack) UnmarshalToWithOptions(data []byte, v interface{}, opts DecodeOptions) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("UnmarshalTo requires a non-nil pointer, got %T", v)
+	}
 
-	case MsgPackTypes.IsMsgPackTypePositiveInt(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.FixIntPos, data, i)
-		if err != nil {
-			return nil, err
-		}
-		return integer, nil
+	r := Reader{data: data, opts: opts}
+	if err := m.decodeValue(&r, rv.Elem()); err != nil {
+		return err
+	}
+	return r.checkTrailingData()
+}
 
-	case MsgPackTypes.IsMsgPackTypeNegativeInt(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.FixIntNeg, data, i)
-		if err != nil {
-			return nil, err
-		}
-		return integer, nil
+// decodeMsgpack decodes the next value of r into its generic Go form.
+func (m *Msgpack) decodeMsgpack(r *Reader) (interface{}, error) {
+	offset := r.Offset()
 
-	case MsgPackTypes.IsMsgPackTypeUint8(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Uint8, data, i)
-		if err != nil {
-			return nil, err
-		}
-		return integer, nil
+	tok, err := r.Next()
+	if err == io.EOF {
+		return nil, fmt.Errorf("data out of range")
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	case MsgPackTypes.IsMsgPackTypeUint16(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Uint16, data, i)
-		if err != nil {
-			return nil, err
-		}
-		return integer, nil
+	switch tok.Kind {
+	case NilKind:
+		return nil, nil
 
-	case MsgPackTypes.IsMsgPackTypeUint32(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Uint32, data, i)
-		if err != nil {
-			return nil, err
-		}
-		return integer, nil
+	case BoolKind:
+		return tok.Bool, nil
 
-	case MsgPackTypes.IsMsgPackTypeUInt64(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Uint64, data, i)
-		if err != nil {
-			return nil, err
-		}
-		return integer, nil
+	case IntKind, UintKind, FloatKind:
+		return m.handleMsgPackTypeNumberFamily(tok, r.opts.NumberMode), nil
 
-	case MsgPackTypes.IsMsgPackTypeInt8(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Int8, data, i)
-		if err != nil {
+	case StrKind, BinKind, ExtKind:
+		if err := r.allocate(len(tok.Bytes)); err != nil {
 			return nil, err
 		}
-		return integer, nil
 
-	case MsgPackTypes.IsMsgPackTypeInt16(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Int16, data, i)
-		if err != nil {
-			return nil, err
+		switch tok.Kind {
+		case StrKind:
+			if r.opts.LegacyRaw {
+				return append([]byte{}, tok.Bytes...), nil
+			}
+			return decodeString(tok.Bytes, r.opts.InvalidUTF8, offset)
+		case BinKind:
+			return append([]byte{}, tok.Bytes...), nil
 		}
-		return integer, nil
-
-	case MsgPackTypes.IsMsgPackTypeInt32(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Int32, data, i)
-		if err != nil {
-			return nil, err
+		if ext, ok := m.extByCode[tok.ExtType]; ok {
+			return decodeExtension(ext, tok.Bytes, offset)
 		}
-		return integer, nil
-
-	case MsgPackTypes.IsMsgPackTypeInt64(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Int64, data, i)
-		if err != nil {
-			return nil, err
+		if tok.ExtType == TimestampExtType {
+			t, err := decodeTime(tok.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("decoding timestamp at offset %d: %w", offset, err)
+			}
+			return t, nil
 		}
-		return integer, nil
-
-	case MsgPackTypes.IsMsgPackTypeFloat32(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Float32, data, i)
-		if err != nil {
-			return nil, err
+		if value, ok, err := decodeBigExt(tok, r.opts.NumberMode); ok {
+			return value, err
 		}
-		return integer, nil
+		return Ext{Type: tok.ExtType, Data: append([]byte{}, tok.Bytes...)}, nil
 
-	case MsgPackTypes.IsMsgPackTypeFloat64(currentByte):
-		integer, err := m.handleMsgPackTypeNumberFamily(MsgPackTypes.Float64, data, i)
-		if err != nil {
+	case ArrayKind, MapKind:
+		if err := r.enter(); err != nil {
 			return nil, err
 		}
-		return integer, nil
+		defer r.leave()
 
-	case MsgPackTypes.IsMsgPackTypeArray(currentByte):
-		arr, err := m.handleMsgPackTypeArray(data, jsonObj, i)
-		if err != nil {
-			return nil, err
+		if tok.Kind == ArrayKind {
+			if err := r.allocate(tok.Length * interfaceSize); err != nil {
+				return nil, err
+			}
+			return m.handleMsgPackTypeArray(r, tok.Length)
 		}
-		return arr, nil
 
-	case MsgPackTypes.IsMsgPackTypeMap(currentByte):
-		obj, err := m.handleMsgPackTypeMap(data, jsonObj, i)
-		if err != nil {
+		if err := r.allocate(tok.Length * mapEntrySize); err != nil {
 			return nil, err
 		}
-
-		return obj, nil
-	}
-	return *jsonObj, nil
-}
-
-func (m *Msgpack) handleMsgPackTypeString(data []byte, jsonObj *map[string]interface{}, i *int) (string, error) {
-	currentByte := data[*i]
-	*i++
-
-	// parse string length
-	strLen := int(currentByte & 0x1F) // 0x1F = 00011111
-
-	// Ensure strLen bytes are available in data
-	if *i+strLen > len(data) {
-		return "", fmt.Errorf("insufficient data for string of length %d", strLen)
+		if r.opts.MapType != nil {
+			return m.decodeMapOfType(r, tok.Length, r.opts.MapType)
+		}
+		return m.handleMsgPackTypeMap(r, tok.Length)
 	}
 
-	// Read string bytes
-	strBytes := data[*i : *i+strLen]
-
-	// Increment pointer by strLen
-	*i += strLen
-
-	return string(strBytes), nil
+	return nil, fmt.Errorf("unknown token kind %s", tok.Kind)
 }
 
-func (m *Msgpack) handleMsgPackTypeArray(data []byte, jsonObj *map[string]interface{}, i *int) ([]interface{}, error) {
-	currentByte := data[*i]
-	*i++
-
-	// parse array length
-	arrLen := int(currentByte & 0x0F) // 0x0F = 00001111
-
+func (m *Msgpack) handleMsgPackTypeArray(r *Reader, arrLen int) ([]interface{}, error) {
 	// create new array
 	arr := make([]interface{}, arrLen)
 
@@ -182,7 +176,7 @@ func (m *Msgpack) handleMsgPackTypeArray(data []byte, jsonObj *map[string]interf
 	// parse array elements
 	for j := 0; j < arrLen; j++ {
 		// parse array element
-		arr[j], err = m.decodeMsgpack(data, jsonObj, i)
+		arr[j], err = m.decodeMsgpack(r)
 		if err != nil {
 			return nil, err
 		}
@@ -191,26 +185,21 @@ func (m *Msgpack) handleMsgPackTypeArray(data []byte, jsonObj *map[string]interf
 	return arr, nil
 }
 
-func (m *Msgpack) handleMsgPackTypeMap(data []byte, jsonObj *map[string]interface{}, i *int) (map[string]interface{}, error) {
-
-	currentByte := data[*i]
-	*i++
+func (m *Msgpack) handleMsgPackTypeMap(r *Reader, mapLen int) (map[string]interface{}, error) {
 
 	// creat new map
 	deepJsonObj := make(map[string]interface{})
 
-	// parse map length
-	mapLen := int(currentByte & 0x0F) // 0x0F = 00001111
 	// parse map key
 	for j := 0; j < mapLen; j++ {
 		// parse map key
-		key, err := m.handleMsgPackTypeString(data, jsonObj, i)
+		key, err := m.handleMsgPackTypeMapKey(r)
 		if err != nil {
 			return nil, err
 		}
 
 		// parse map value
-		value, err := m.decodeMsgpack(data, jsonObj, i)
+		value, err := m.decodeMsgpack(r)
 		if err != nil {
 			return nil, err
 		}
@@ -222,93 +211,146 @@ func (m *Msgpack) handleMsgPackTypeMap(data []byte, jsonObj *map[string]interfac
 	return deepJsonObj, nil
 }
 
-// handleMsgPackTypeNumberFamily handles number family types
-func (m *Msgpack) handleMsgPackTypeNumberFamily(t int, data []byte, i *int) (interface{}, error) {
+// decodeMapOfType decodes mapLen key-value pairs into a new map of type t,
+// which has string keys or interface{} keys that also take values other than strings.
+func (m *Msgpack) decodeMapOfType(r *Reader, mapLen int, t reflect.Type) (interface{}, error) {
+	if t.Kind() != reflect.Map || t.Key().Kind() != reflect.String && t.Key() != interfaceType {
+		return nil, fmt.Errorf("unsupported map type %s", t)
+	}
 
-	currentByte := data[*i]
-	*i++
+	// creat new map
+	rv := reflect.MakeMapWithSize(t, mapLen)
 
-	var value interface{}
-	var err error
-	var bytes []byte
-	length := 0
+	for j := 0; j < mapLen; j++ {
+		// parse map key
+		var key reflect.Value
+		if t.Key().Kind() == reflect.String {
+			k, err := m.handleMsgPackTypeMapKey(r)
+			if err != nil {
+				return nil, err
+			}
+			key = reflect.ValueOf(k).Convert(t.Key())
+		} else {
+			offset := r.Offset()
+			k, err := m.decodeMsgpack(r)
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, fmt.Errorf("unhashable map key of type %T at offset %d", k, offset)
+			}
+			key = reflect.ValueOf(&k).Elem()
+		}
+
+		// parse map value
+		elem := reflect.New(t.Elem()).Elem()
+		if err := m.decodeValue(r, elem); err != nil {
+			return nil, err
+		}
+
+		rv.SetMapIndex(key, elem)
+	}
 
-	switch t {
+	return rv.Interface(), nil
+}
+
+// handleMsgPackTypeMapKey reads a map key, only string keys are supported
+func (m *Msgpack) handleMsgPackTypeMapKey(r *Reader) (string, error) {
+	offset := r.Offset()
 
-	case MsgPackTypes.FixIntPos:
-		value = int(currentByte & 0x7F) // 0x7F = 01111111
+	tok, err := r.Next()
+	if err == io.EOF {
+		return "", fmt.Errorf("data out of range")
+	}
+	if err != nil {
+		return "", err
+	}
 
-	case MsgPackTypes.FixIntNeg:
-		value = int(int8(currentByte))
+	if tok.Kind != StrKind {
+		return "", fmt.Errorf("expected string map key at offset %d, got %s", offset, tok.Kind)
+	}
+	if err := r.allocate(len(tok.Bytes)); err != nil {
+		return "", err
+	}
+	return decodeMapKey(tok.Bytes, r.opts.InvalidUTF8, offset)
+}
 
+// handleMsgPackTypeNumberFamily converts a number token to the Go type selected by mode
+func (m *Msgpack) handleMsgPackTypeNumberFamily(tok Token, mode NumberMode) interface{} {
+	switch mode {
+	case NumberInt64:
+		switch {
+		case tok.Kind == IntKind:
+			return tok.Int
+		case tok.Kind == UintKind && tok.Uint <= math.MaxInt64:
+			return int64(tok.Uint)
+		case tok.Kind == UintKind:
+			return tok.Uint
+		}
+		return tok.Float
+
+	case NumberFloat64:
+		switch tok.Kind {
+		case IntKind:
+			return float64(tok.Int)
+		case UintKind:
+			return float64(tok.Uint)
+		}
+		return tok.Float
+
+	case NumberJSON:
+		switch tok.Kind {
+		case IntKind:
+			return json.Number(strconv.FormatInt(tok.Int, 10))
+		case UintKind:
+			return json.Number(strconv.FormatUint(tok.Uint, 10))
+		}
+		// format float 32 values with the precision they were written with
+		bitSize := 64
+		if tok.Code == MsgPackTypes.Float32 {
+			bitSize = 32
+		}
+		return json.Number(strconv.FormatFloat(tok.Float, 'g', -1, bitSize))
+	}
+
+	// NumberWireType
+	switch {
+	case MsgPackTypes.IsMsgPackTypePositiveInt(tok.Code):
+		return int(tok.Uint)
+
+	case MsgPackTypes.IsMsgPackTypeNegativeInt(tok.Code):
+		return int(tok.Int)
+	}
+
+	switch tok.Code {
 	case MsgPackTypes.Uint8:
-		length = 1
-		bytes, err = m.getNextBytes(data, i, length)
-		value = uint8(bytes[0])
+		return uint8(tok.Uint)
 
 	case MsgPackTypes.Uint16:
-		length = 2
-		bytes, err = m.getNextBytes(data, i, length)
-		value = binary.BigEndian.Uint16(bytes)
+		return uint16(tok.Uint)
 
 	case MsgPackTypes.Uint32:
-		length = 4
-		bytes, err = m.getNextBytes(data, i, length)
-		value = binary.BigEndian.Uint32(bytes)
-
-	case MsgPackTypes.Uint64:
-		length = 8
-		bytes, err = m.getNextBytes(data, i, length)
-		value = binary.BigEndian.Uint64(bytes)
+		return uint32(tok.Uint)
 
 	case MsgPackTypes.Int8:
-		length = 1
-		bytes, err = m.getNextBytes(data, i, length)
-		value = int8(bytes[0])
+		return int8(tok.Int)
 
 	case MsgPackTypes.Int16:
-		length = 2
-		bytes, err = m.getNextBytes(data, i, length)
-		value = int16(binary.BigEndian.Uint16(bytes))
+		return int16(tok.Int)
 
 	case MsgPackTypes.Int32:
-		length = 4
-		bytes, err = m.getNextBytes(data, i, length)
-		value = int32(binary.BigEndian.Uint32(bytes))
+		return int32(tok.Int)
 
 	case MsgPackTypes.Int64:
-		length = 8
-		bytes, err = m.getNextBytes(data, i, length)
-		value = int64(binary.BigEndian.Uint64(bytes))
+		return tok.Int
 
 	case MsgPackTypes.Float32:
-		length = 4
-		bytes, err = m.getNextBytes(data, i, length)
-		value = math.Float32frombits(binary.BigEndian.Uint32(bytes))
+		return float32(tok.Float)
 
 	case MsgPackTypes.Float64:
-		length = 8
-		bytes, err = m.getNextBytes(data, i, length)
-		value = math.Float64frombits(binary.BigEndian.Uint64(bytes))
-
-	default:
-		return nil, fmt.Errorf("unknown int family type %d", t)
-	}
-
-	if err != nil {
-		return nil, err
+		return tok.Float
 	}
-	return value, nil
-}
-
-// getNextBytes returns the next n bytes from data
-func (m *Msgpack) getNextBytes(data []byte, i *int, length int) ([]byte, error) {
-	if *i+length > len(data) {
-		return nil, fmt.Errorf("data out of range")
-	}
-
-	value := data[*i : *i+length]
-	*i += length
 
-	return value, nil
+	// MsgPackTypes.Uint64
+	return tok.Uint
 }
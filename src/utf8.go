@@ -0,0 +1,86 @@
+package msgpack
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// UTF8Policy selects what happens to strings that are not valid UTF-8.
+type UTF8Policy int
+
+const (
+	// UTF8Allow keeps invalid strings as they are.
+	UTF8Allow UTF8Policy = iota
+	// UTF8Error reports invalid strings with an error wrapping ErrInvalidUTF8.
+	UTF8Error
+	// UTF8Replace replaces each run of invalid bytes with U+FFFD.
+	UTF8Replace
+	// UTF8AsBytes keeps invalid strings as bin values on encode and as []byte on decode.
+	UTF8AsBytes
+)
+
+// ErrInvalidUTF8 is returned for invalid strings under the UTF8Error policy.
+var ErrInvalidUTF8 = errors.New("invalid UTF-8 string")
+
+// encodeString writes s as a str value, applying the UTF-8 policy of opts.
+func (m *Msgpack) encodeString(result *[]byte, s string, opts *encodeState) error {
+	if opts.InvalidUTF8 != UTF8Allow && !utf8.ValidString(s) {
+		switch opts.InvalidUTF8 {
+		case UTF8Error:
+			return fmt.Errorf("%w: %q", ErrInvalidUTF8, s)
+		case UTF8Replace:
+			s = strings.ToValidUTF8(s, "\uFFFD")
+		case UTF8AsBytes:
+			m.encodeBytes(result, []byte(s), opts)
+			return nil
+		}
+	}
+
+	if opts.LegacyRaw {
+		*result = append(appendRawHeader(*result, len(s)), s...)
+		return nil
+	}
+	*result = AppendString(*result, s)
+	return nil
+}
+
+// encodeMapKey writes a map key, keys cannot be bin values so UTF8AsBytes reports invalid keys.
+func (m *Msgpack) encodeMapKey(result *[]byte, key string, opts *encodeState) error {
+	if opts.InvalidUTF8 == UTF8AsBytes && !utf8.ValidString(key) {
+		return fmt.Errorf("%w: map key %q", ErrInvalidUTF8, key)
+	}
+	return m.encodeString(result, key, opts)
+}
+
+// decodeString converts the bytes of a str value at offset according to policy.
+func decodeString(b []byte, policy UTF8Policy, offset int) (interface{}, error) {
+	if policy == UTF8Allow || utf8.Valid(b) {
+		return string(b), nil
+	}
+
+	switch policy {
+	case UTF8Error:
+		return nil, fmt.Errorf("%w at offset %d", ErrInvalidUTF8, offset)
+	case UTF8Replace:
+		return strings.ToValidUTF8(string(b), "\uFFFD"), nil
+	}
+
+	// UTF8AsBytes
+	return append([]byte{}, b...), nil
+}
+
+// decodeMapKey converts the bytes of a map key at offset according to policy,
+// map keys stay strings so UTF8AsBytes keeps them as they are.
+func decodeMapKey(b []byte, policy UTF8Policy, offset int) (string, error) {
+	if policy == UTF8AsBytes {
+		return string(b), nil
+	}
+
+	key, err := decodeString(b, policy, offset)
+	if err != nil {
+		return "", err
+	}
+	return key.(string), nil
+}
@@ -0,0 +1,118 @@
+package msgpack
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// {"name": "a\xffb"}
+var invalidUTF8InputBytes = []byte{0x81, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa3, 0x61, 0xff, 0x62}
+
+func TestUnmarshalInvalidUTF8(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		policy   UTF8Policy
+		expected interface{}
+		err      error
+	}{
+		{
+			desc:     "Test Case - allow",
+			policy:   UTF8Allow,
+			expected: "a\xffb",
+		},
+		{
+			desc:   "Test Case - error",
+			policy: UTF8Error,
+			err:    ErrInvalidUTF8,
+		},
+		{
+			desc:     "Test Case - replace",
+			policy:   UTF8Replace,
+			expected: "a\uFFFDb",
+		},
+		{
+			desc:     "Test Case - as bytes",
+			policy:   UTF8AsBytes,
+			expected: []byte{0x61, 0xff, 0x62},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output, err := mp.UnmarshalWithOptions(invalidUTF8InputBytes, DecodeOptions{InvalidUTF8: tc.policy})
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, output["name"])
+		})
+	}
+}
+
+func TestUnmarshalValidUTF8(t *testing.T) {
+	mp := NewMsgpack()
+
+	// {"name": "許"}
+	output, err := mp.UnmarshalWithOptions([]byte{0x81, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa3, 0xe8, 0xa8, 0xb1}, DecodeOptions{InvalidUTF8: UTF8Error})
+	require.NoError(t, err)
+	require.Equal(t, "許", output["name"])
+}
+
+func TestMarshalInvalidUTF8(t *testing.T) {
+	mp := NewMsgpack()
+
+	testCases := []struct {
+		desc     string
+		policy   UTF8Policy
+		jsonObj  map[string]interface{}
+		expected []byte
+		err      error
+	}{
+		{
+			desc:     "Test Case - allow",
+			policy:   UTF8Allow,
+			jsonObj:  map[string]interface{}{"name": "a\xffb"},
+			expected: invalidUTF8InputBytes,
+		},
+		{
+			desc:    "Test Case - error",
+			policy:  UTF8Error,
+			jsonObj: map[string]interface{}{"name": "a\xffb"},
+			err:     ErrInvalidUTF8,
+		},
+		{
+			desc:     "Test Case - replace",
+			policy:   UTF8Replace,
+			jsonObj:  map[string]interface{}{"name": "a\xffb"},
+			expected: []byte{0x81, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xa5, 0x61, 0xef, 0xbf, 0xbd, 0x62},
+		},
+		{
+			desc:     "Test Case - as bytes",
+			policy:   UTF8AsBytes,
+			jsonObj:  map[string]interface{}{"name": "a\xffb"},
+			expected: []byte{0x81, 0xa4, 0x6e, 0x61, 0x6d, 0x65, 0xc4, 0x03, 0x61, 0xff, 0x62},
+		},
+		{
+			desc:    "Test Case - as bytes on key",
+			policy:  UTF8AsBytes,
+			jsonObj: map[string]interface{}{"a\xffb": "name"},
+			err:     ErrInvalidUTF8,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			m1, err := mp.MarshalWithOptions(tc.jsonObj, EncodeOptions{InvalidUTF8: tc.policy})
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, m1, "The two MessagePack byte should be equal")
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package msgpack
+
+import (
+	"encoding/binary"
+	"math"
+
+	MsgPackTypes "msgpack/src/types"
+)
+
+// AppendNil appends a nil value to dst.
+func AppendNil(dst []byte) []byte {
+	return append(dst, MsgPackTypes.Nil)
+}
+
+// AppendBool appends a boolean value to dst.
+func AppendBool(dst []byte, b bool) []byte {
+	if b {
+		return append(dst, MsgPackTypes.True)
+	}
+	return append(dst, MsgPackTypes.False)
+}
+
+// AppendInt appends an integer to dst using the smallest encoding that holds it.
+// Non-negative values use the unsigned encodings.
+func AppendInt(dst []byte, i int64) []byte {
+	switch {
+	case i >= 0:
+		return AppendUint(dst, uint64(i))
+
+	case i >= -32:
+		return append(dst, byte(i))
+
+	case i >= math.MinInt8:
+		return append(dst, MsgPackTypes.Int8, byte(i))
+
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Int16), uint16(i))
+
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Int32), uint32(i))
+
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, MsgPackTypes.Int64), uint64(i))
+	}
+}
+
+// AppendUint appends an unsigned integer to dst using the smallest encoding that holds it.
+func AppendUint(dst []byte, u uint64) []byte {
+	switch {
+	case u <= 127:
+		return append(dst, byte(u))
+
+	case u <= math.MaxUint8:
+		return append(dst, MsgPackTypes.Uint8, byte(u))
+
+	case u <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Uint16), uint16(u))
+
+	case u <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Uint32), uint32(u))
+
+	default:
+		return binary.BigEndian.AppendUint64(append(dst, MsgPackTypes.Uint64), u)
+	}
+}
+
+// AppendFloat32 appends a float32 to dst.
+func AppendFloat32(dst []byte, f float32) []byte {
+	return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Float32), math.Float32bits(f))
+}
+
+// AppendFloat64 appends a float64 to dst.
+func AppendFloat64(dst []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(append(dst, MsgPackTypes.Float64), math.Float64bits(f))
+}
+
+// AppendString appends s to dst as a str value.
+func AppendString(dst []byte, s string) []byte {
+	return append(appendStringHeader(dst, len(s)), s...)
+}
+
+// AppendBytes appends b to dst as a bin value.
+func AppendBytes(dst []byte, b []byte) []byte {
+	return append(appendBinHeader(dst, len(b)), b...)
+}
+
+// AppendArrayHeader appends the header of an array of n elements to dst.
+// The n elements have to be appended after it.
+func AppendArrayHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(dst, byte(n)+MsgPackTypes.FixArray)
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Array16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Array32), uint32(n))
+	}
+}
+
+// AppendMapHeader appends the header of a map of n key-value pairs to dst.
+// The n keys and values have to be appended after it, each key followed by its value.
+func AppendMapHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(dst, byte(n)+MsgPackTypes.FixMap)
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Map16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Map32), uint32(n))
+	}
+}
+
+// AppendExt appends an extension value of type typ to dst,
+// a fixext encoding is used when the length of data allows it.
+func AppendExt(dst []byte, typ int8, data []byte) []byte {
+	switch n := len(data); {
+	case n == 1:
+		dst = append(dst, MsgPackTypes.FixExt1)
+	case n == 2:
+		dst = append(dst, MsgPackTypes.FixExt2)
+	case n == 4:
+		dst = append(dst, MsgPackTypes.FixExt4)
+	case n == 8:
+		dst = append(dst, MsgPackTypes.FixExt8)
+	case n == 16:
+		dst = append(dst, MsgPackTypes.FixExt16)
+	case n <= math.MaxUint8:
+		dst = append(dst, MsgPackTypes.Ext8, byte(n))
+	case n <= math.MaxUint16:
+		dst = binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Ext16), uint16(n))
+	default:
+		dst = binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Ext32), uint32(n))
+	}
+
+	dst = append(dst, byte(typ))
+	return append(dst, data...)
+}
+
+// appendStringHeader appends the smallest str header for a string of n bytes.
+func appendStringHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= 31:
+		return append(dst, byte(n)+MsgPackTypes.FixStr)
+	case n <= math.MaxUint8:
+		return append(dst, MsgPackTypes.Str8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Str16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Str32), uint32(n))
+	}
+}
+
+// appendRawHeader appends the smallest raw header of the pre-2013 spec, which has no 8-bit length.
+func appendRawHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= 31:
+		return append(dst, byte(n)+MsgPackTypes.FixRaw)
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Raw16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Raw32), uint32(n))
+	}
+}
+
+// appendBinHeader appends the smallest bin header for n bytes.
+func appendBinHeader(dst []byte, n int) []byte {
+	switch {
+	case n <= math.MaxUint8:
+		return append(dst, MsgPackTypes.Bin8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(dst, MsgPackTypes.Bin16), uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32(append(dst, MsgPackTypes.Bin32), uint32(n))
+	}
+}
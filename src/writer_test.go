@@ -0,0 +1,80 @@
+package msgpack
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendPrimitives(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		output   []byte
+		expected []byte
+	}{
+		{"Test Case - nil", AppendNil(nil), []byte{0xc0}},
+		{"Test Case - true", AppendBool(nil, true), []byte{0xc3}},
+		{"Test Case - false", AppendBool(nil, false), []byte{0xc2}},
+		{"Test Case - positive fixint", AppendInt(nil, 18), []byte{0x12}},
+		{"Test Case - negative fixint", AppendInt(nil, -18), []byte{0xee}},
+		{"Test Case - int8", AppendInt(nil, -128), []byte{0xd0, 0x80}},
+		{"Test Case - int16", AppendInt(nil, -32768), []byte{0xd1, 0x80, 0x00}},
+		{"Test Case - int32", AppendInt(nil, math.MinInt32), []byte{0xd2, 0x80, 0x00, 0x00, 0x00}},
+		{"Test Case - int64", AppendInt(nil, math.MinInt64), []byte{0xd3, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		{"Test Case - positive int as uint8", AppendInt(nil, 200), []byte{0xcc, 0xc8}},
+		{"Test Case - uint8", AppendUint(nil, 255), []byte{0xcc, 0xff}},
+		{"Test Case - uint16", AppendUint(nil, 65535), []byte{0xcd, 0xff, 0xff}},
+		{"Test Case - uint32", AppendUint(nil, math.MaxUint32), []byte{0xce, 0xff, 0xff, 0xff, 0xff}},
+		{"Test Case - uint64", AppendUint(nil, math.MaxUint64), []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}},
+		{"Test Case - float32", AppendFloat32(nil, 3.1415927), []byte{0xca, 0x40, 0x49, 0x0f, 0xdb}},
+		{"Test Case - float64", AppendFloat64(nil, math.Pi), []byte{0xcb, 0x40, 0x09, 0x21, 0xfb, 0x54, 0x44, 0x2d, 0x18}},
+		{"Test Case - fixstr", AppendString(nil, "asd"), []byte{0xa3, 0x61, 0x73, 0x64}},
+		{"Test Case - str8", AppendString(nil, string(bytes.Repeat([]byte{0x61}, 32)))[:2], []byte{0xd9, 0x20}},
+		{"Test Case - str16", AppendString(nil, string(bytes.Repeat([]byte{0x61}, 256)))[:3], []byte{0xda, 0x01, 0x00}},
+		{"Test Case - bin8", AppendBytes(nil, []byte{0x01, 0x02}), []byte{0xc4, 0x02, 0x01, 0x02}},
+		{"Test Case - bin16", AppendBytes(nil, make([]byte, 256))[:3], []byte{0xc5, 0x01, 0x00}},
+		{"Test Case - fixarray", AppendArrayHeader(nil, 15), []byte{0x9f}},
+		{"Test Case - array16", AppendArrayHeader(nil, 16), []byte{0xdc, 0x00, 0x10}},
+		{"Test Case - array32", AppendArrayHeader(nil, 65536), []byte{0xdd, 0x00, 0x01, 0x00, 0x00}},
+		{"Test Case - fixmap", AppendMapHeader(nil, 1), []byte{0x81}},
+		{"Test Case - map16", AppendMapHeader(nil, 16), []byte{0xde, 0x00, 0x10}},
+		{"Test Case - fixext1", AppendExt(nil, 5, []byte{0x07}), []byte{0xd4, 0x05, 0x07}},
+		{"Test Case - fixext4", AppendExt(nil, -1, []byte{0x00, 0x00, 0x00, 0x01}), []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x01}},
+		{"Test Case - ext8", AppendExt(nil, 5, []byte{0x01, 0x02, 0x03}), []byte{0xc7, 0x03, 0x05, 0x01, 0x02, 0x03}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.output, "The two MessagePack byte should be equal")
+		})
+	}
+}
+
+func TestAppendHandRolledMessage(t *testing.T) {
+	mp := NewMsgpack()
+
+	var msg []byte
+	msg = AppendMapHeader(msg, 2)
+	msg = AppendString(msg, "id")
+	msg = AppendInt(msg, -300)
+	msg = AppendString(msg, "tags")
+	msg = AppendArrayHeader(msg, 2)
+	msg = AppendBytes(msg, []byte{0x01})
+	msg = AppendExt(msg, 5, []byte{0x07})
+
+	output, err := mp.Unmarshal(msg)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"id":   int16(-300),
+		"tags": []interface{}{[]byte{0x01}, Ext{Type: 5, Data: []byte{0x07}}},
+	}, output)
+
+	m1, err := mp.Marshal(output)
+	require.NoError(t, err)
+
+	roundTrip, err := mp.Unmarshal(m1)
+	require.NoError(t, err)
+	require.Equal(t, output, roundTrip)
+}